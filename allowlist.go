@@ -0,0 +1,62 @@
+package mitake
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AllowList restricts the recipients a client sends to, for non-production
+// environments where only the phones of the team may receive SMS.
+//
+// A recipient that is not on the list is dropped with the StatusRecipientNotAllowed
+// status, or, when Redirect is set, the message is sent to Redirect instead, with
+// the original recipient prefixed to the body.
+type AllowList struct {
+	Numbers  []string // Allowed phone numbers
+	Redirect string   // Phone number to redirect the other recipients to
+}
+
+// Allowed reports whether the phone number is on the allow-list.
+func (a *AllowList) Allowed(dstaddr string) bool {
+	n := normalizePhoneNumber(dstaddr)
+	return slices.ContainsFunc(a.Numbers, func(number string) bool {
+		return normalizePhoneNumber(number) == n
+	})
+}
+
+func (a *AllowList) apply(p *preparedMessage) {
+	if a.Allowed(p.Dstaddr) {
+		return
+	}
+	if a.Redirect == "" {
+		p.drop(StatusRecipientNotAllowed)
+		return
+	}
+	original := p.Dstaddr
+	p.Dstaddr = a.Redirect
+	p.Smbody = fmt.Sprintf("[%s] %s", original, p.Smbody)
+	p.annotations = append(p.annotations, func(result *MessageResult) {
+		result.RedirectedFrom = original
+	})
+}
+
+// normalizePhoneNumber returns the phone number in the local format 09XXXXXXXX,
+// without separators.
+func normalizePhoneNumber(s string) string {
+	s = strings.TrimPrefix(stripPhoneSeparators(s), "+")
+	if strings.HasPrefix(s, "886") {
+		s = "0" + strings.TrimPrefix(s[3:], "0")
+	}
+	return s
+}
+
+// stripPhoneSeparators removes the spaces, dashes and parentheses of a phone number.
+func stripPhoneSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '(' || r == ')' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,104 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAllowList_Allowed(t *testing.T) {
+	a := &AllowList{Numbers: []string{"0987-654-321", "+886912345678"}}
+
+	for _, number := range []string{"0987654321", "+886987654321", "0912345678", "886 912 345 678"} {
+		if !a.Allowed(number) {
+			t.Errorf("Allowed(%q) returned false", number)
+		}
+	}
+	if a.Allowed("0911111111") {
+		t.Error(`Allowed("0911111111") returned true`)
+	}
+}
+
+func TestClient_Send_allowListDrop(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.AllowList = &AllowList{Numbers: []string{"0987654321"}}
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Send sent a message to a recipient not on the allow-list")
+	})
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{ClientID: "0aab", Dstaddr: "0911111111", Smbody: "Hello"},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+
+	want := &MessageResponse{Results: []*MessageResult{{ClientID: "0aab", StatusCode: StatusRecipientNotAllowed}}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("Send returned %+v, want %+v", resp, want)
+	}
+}
+
+func TestClient_Send_allowListRedirect(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.AllowList = &AllowList{Numbers: []string{"0987654321"}, Redirect: "0987654321"}
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		testFormData(t, r, map[string][]string{
+			"username":     {"username"},
+			"password":     {"password"},
+			"dstaddr":      {"0987654321"},
+			"smbody":       {"[0911111111] Hello"},
+			"smsPointFlag": {"1"},
+		})
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{Dstaddr: "0911111111", Smbody: "Hello"},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if got, want := resp.Results[0].RedirectedFrom, "0911111111"; got != want {
+		t.Errorf("Send returned RedirectedFrom %v, want %v", got, want)
+	}
+}
+
+func TestClient_SendBatch_allowList(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.AllowList = &AllowList{Numbers: []string{"0987654321"}}
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		testData(t, r, "1aab$$0987654321$$$$$$$$$$Test2\r\n")
+		_, _ = fmt.Fprint(w, "[1aab]\nmsgid=#1010079523\nstatuscode=1\nAccountPoint=98\n")
+	})
+
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		HideDeductedPoints: true,
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0911111111", Smbody: "Test1"},
+			{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Test2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	want := &MessageResponse{
+		Results: []*MessageResult{
+			{ClientID: "0aab", StatusCode: StatusRecipientNotAllowed},
+			{ClientID: "1aab", Msgid: "#1010079523", StatusCode: StatusCarrierAccepted},
+		},
+		AccountPoint: 98,
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("SendBatch returned %+v, want %+v", resp, want)
+	}
+}
@@ -15,17 +15,16 @@ type MessageParams struct {
 	Encoding           string // The encoding of the message body
 	ObjectID           string // Name fo the batch
 	HideDeductedPoints bool   // Set to true to hide the points deducted per SMS in the response
+	ShowDeductedPoints bool   // Set to true to show the points deducted per SMS even if the Defaults of the client hide them
 	Message
 }
 
+// Validate returns a ValidationError listing the invalid fields of the message,
+// or nil.
 func (p MessageParams) Validate() error {
-	if p.Dstaddr == "" {
-		return &ParameterError{Reason: "empty Dstaddr"}
-	}
-	if p.Smbody == "" {
-		return &ParameterError{Reason: "empty Smbody"}
-	}
-	return nil
+	ve := new(ValidationError)
+	validateMessage(ve, p.Message)
+	return ve.err()
 }
 
 // ToData converts the message to url.Values for sending.
@@ -58,11 +57,51 @@ func (p MessageParams) ToData() url.Values {
 }
 
 // Send sends a SMS.
+//
+// If the client has an ObjectRegistry and recording the msgid fails, Send returns
+// the response together with the error.
 func (c *Client) Send(ctx context.Context, params MessageParams) (*MessageResponse, error) {
+	c.fillClientID(&params.Message)
 	if err := params.Validate(); err != nil {
 		return nil, err
 	}
 
+	p, err := c.prepareMessage(ctx, params.Message)
+	if err != nil {
+		return nil, err
+	}
+	if p.result != nil {
+		return &MessageResponse{Results: []*MessageResult{p.result}}, nil
+	}
+	params.Message = p.Message
+
+	var response *MessageResponse
+	if retry := c.policy(params.Message).Retry; retry != nil {
+		response, err = retry.do(ctx, params.ClientID, func() (*MessageResponse, error) {
+			return c.send(ctx, params)
+		})
+	} else {
+		response, err = c.send(ctx, params)
+	}
+	if err != nil {
+		p.settle(ctx, nil)
+		return nil, err
+	}
+	// The single send response is keyed by a sequence number rather than the ClientID.
+	for _, result := range response.Results {
+		result.ClientID = params.ClientID
+		p.apply(result)
+	}
+	if len(response.Results) > 0 {
+		p.settle(ctx, response.Results[0])
+	} else {
+		p.settle(ctx, nil)
+	}
+	return response, c.recordObject(ctx, params.ObjectID, response)
+}
+
+// send posts a single message.
+func (c *Client) send(ctx context.Context, params MessageParams) (*MessageResponse, error) {
 	u, _ := url.Parse("b2c/mtk/SmSend")
 	u.RawQuery = c.buildSendQuery(params).Encode()
 	data := c.buildSendFormData(params)
@@ -77,16 +116,15 @@ func (c *Client) Send(ctx context.Context, params MessageParams) (*MessageRespon
 }
 
 func (c *Client) buildSendQuery(params MessageParams) url.Values {
-	encoding := defaultEncoding
-	if params.Encoding != "" {
-		encoding = params.Encoding
-	}
+	encoding := c.encoding(params.Encoding)
 	q := url.Values{}
 	q.Set("CharsetURL", encoding)
 	return q
 }
 
 func (c *Client) buildSendFormData(params MessageParams) url.Values {
+	params.ObjectID = c.objectID(params.ObjectID)
+	params.HideDeductedPoints = c.hideDeductedPoints(params.HideDeductedPoints, params.ShowDeductedPoints)
 	data := params.ToData()
 	data.Set("username", c.username)
 	data.Set("password", c.password)
@@ -97,49 +135,79 @@ type BatchMessagesParams struct {
 	Encoding           string `json:"Encoding_postIn"` // The encoding of the message body
 	ObjectID           string `json:"objectID"`        // Name fo the batch
 	HideDeductedPoints bool   // Set to true to hide the points deducted per SMS in the response
+	ShowDeductedPoints bool   // Set to true to show the points deducted per SMS even if the Defaults of the client hide them
 	Messages           []Message
+	BatchSize          int // Maximum number of messages per request of SendBatchFrom, defaults to MaxBatchMessages
 }
 
-func (p BatchMessagesParams) Validate() error {
-	if len(p.Messages) == 0 {
-		return &ParameterError{Reason: "empty messages"}
-	}
-	for i, message := range p.Messages {
-		if message.ClientID == "" {
-			return &ParameterError{Reason: fmt.Sprintf("%d: empty ClientID", i)}
-		}
-		if message.Dstaddr == "" {
-			return &ParameterError{Reason: fmt.Sprintf("%d: [%s] empty Dstaddr", i, message.ClientID)}
-		}
-		if message.Smbody == "" {
-			return &ParameterError{Reason: fmt.Sprintf("%d: [%s] empty Smbody", i, message.ClientID)}
-		}
-	}
-	return nil
-}
-
+// ToData converts the messages to the bulk format for sending.
 func (p BatchMessagesParams) ToData() string {
-	var data string
+	var b strings.Builder
 	for _, message := range p.Messages {
-		data += fmt.Sprintf("%s$$%s$$%s$$%s$$%s$$%s$$%s\r\n",
-			message.ClientID,
-			message.Dstaddr,
-			message.Dlvtime,
-			message.Vldtime,
-			message.Destname,
-			message.Response,
-			message.Smbody,
-		)
+		_ = writeBatchMessage(&b, message)
 	}
-	return data
+	return b.String()
+}
+
+// batchFieldSeparator separates the fields of a line of the bulk format.
+const batchFieldSeparator = "$$"
+
+// newlineReplacer converts line breaks to ASCII code 6, which Mitake renders as a
+// new line, so that a message body always fits on a single line of the bulk format.
+var newlineReplacer = strings.NewReplacer("\r\n", "\x06", "\r", "\x06", "\n", "\x06")
+
+// writeBatchMessage writes the message to w as a line of the bulk format.
+func writeBatchMessage(w io.Writer, message Message) error {
+	_, err := fmt.Fprintf(w, "%s$$%s$$%s$$%s$$%s$$%s$$%s\r\n",
+		message.ClientID,
+		message.Dstaddr,
+		message.Dlvtime,
+		message.Vldtime,
+		message.Destname,
+		message.Response,
+		newlineReplacer.Replace(message.Smbody),
+	)
+	return err
 }
 
 // SendBatch sends multiple SMS.
+//
+// If the client has an ObjectRegistry and recording the msgids fails, SendBatch
+// returns the response together with the error.
 func (c *Client) SendBatch(ctx context.Context, opts BatchMessagesParams) (*MessageResponse, error) {
+	opts.Messages = c.fillClientIDs(opts.Messages)
 	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
 
+	prepared, err := c.prepareMessages(ctx, opts.Messages)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(prepared))
+	for _, p := range prepared {
+		if p.result == nil {
+			messages = append(messages, p.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return &MessageResponse{Results: mergeResults(prepared, nil)}, nil
+	}
+	opts.Messages = messages
+
+	response, err := c.sendBatch(ctx, opts)
+	if err != nil {
+		settleMessages(ctx, prepared, err)
+		return nil, err
+	}
+	err = c.recordObject(ctx, opts.ObjectID, response)
+	response.Results = mergeResults(prepared, response)
+	settleMessages(ctx, prepared, nil)
+	return response, err
+}
+
+// sendBatch posts the messages of opts as they are.
+func (c *Client) sendBatch(ctx context.Context, opts BatchMessagesParams) (*MessageResponse, error) {
 	u, _ := url.Parse("b2c/mtk/SmBulkSend")
 	u.RawQuery = c.buildSendBatchQuery(opts).Encode()
 	data := opts.ToData()
@@ -154,19 +222,16 @@ func (c *Client) SendBatch(ctx context.Context, opts BatchMessagesParams) (*Mess
 }
 
 func (c *Client) buildSendBatchQuery(opts BatchMessagesParams) url.Values {
-	encoding := defaultEncoding
-	if opts.Encoding != "" {
-		encoding = opts.Encoding
-	}
+	encoding := c.encoding(opts.Encoding)
 
 	q := url.Values{}
 	q.Set("username", c.username)
 	q.Set("password", c.password)
 	q.Set("Encoding_PostIn", encoding)
-	if opts.ObjectID != "" {
-		q.Set("objectID", opts.ObjectID)
+	if objectID := c.objectID(opts.ObjectID); objectID != "" {
+		q.Set("objectID", objectID)
 	}
-	if !opts.HideDeductedPoints {
+	if !c.hideDeductedPoints(opts.HideDeductedPoints, opts.ShowDeductedPoints) {
 		q.Set("smsPointFlag", "1")
 	}
 	return q
@@ -174,9 +239,17 @@ func (c *Client) buildSendBatchQuery(opts BatchMessagesParams) url.Values {
 
 // MessageResult represents result of send SMS.
 type MessageResult struct {
+	ClientID   string // The ClientID of the message, only available for batch sends or when set on the message
 	Msgid      string
 	StatusCode StatusCode
 	SmsPoint   *int // Points deducted per SMS, only available when SmsPointFlag is set
+	// AlreadySent reports that Mitake had already accepted a message with the same
+	// ClientID (Duplicate=Y), and did not send it again. Msgid is the first one's.
+	AlreadySent bool
+
+	RedirectedFrom string // The original recipient when the AllowList redirected the message
+	DeferredTo     string // The Dlvtime set when QuietHours deferred the message, format: YYYYMMDDHHMMSS
+	Account        string // The name of the account that sent the message, only set by a Pool
 }
 
 // MessageResponse represents response of send SMS.
@@ -187,25 +260,44 @@ type MessageResponse struct {
 }
 
 func parseMessageResponse(body io.Reader) (*MessageResponse, error) {
+	response := new(MessageResponse)
+	err := scanMessageResponse(body, response, func(result *MessageResult) bool {
+		response.Results = append(response.Results, result)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) == 0 {
+		return nil, &UnexpectedResponseError{Reason: "invalid response"}
+	}
+	return response, nil
+}
+
+// scanMessageResponse parses a send response, calling yield with each result once
+// all of its fields have been read. The response level fields are stored in
+// response. Scanning stops early when yield returns false.
+func scanMessageResponse(body io.Reader, response *MessageResponse, yield func(*MessageResult) bool) error {
 	var (
-		scanner  = bufio.NewScanner(body)
-		re       = regexp.MustCompile(`^\[(.+?)]$`)
-		response = new(MessageResponse)
-		result   *MessageResult
+		scanner = bufio.NewScanner(body)
+		re      = regexp.MustCompile(`^\[(.+?)]$`)
+		result  *MessageResult
 	)
 	for scanner.Scan() {
 		text := strings.TrimSpace(scanner.Text())
 
-		if matched := re.MatchString(text); matched {
-			result = new(MessageResult)
-			response.Results = append(response.Results, result)
+		if matches := re.FindStringSubmatch(text); matches != nil {
+			if result != nil && !yield(result) {
+				return nil
+			}
+			result = &MessageResult{ClientID: matches[1]}
 		} else {
 			if result == nil {
-				return nil, &UnexpectedResponseError{Reason: "no clientid"}
+				return &UnexpectedResponseError{Reason: "no clientid"}
 			}
 			s := strings.Split(text, "=")
 			if len(s) != 2 {
-				return nil, &UnexpectedResponseError{Reason: "invalid key value pair"}
+				return &UnexpectedResponseError{Reason: "invalid key value pair"}
 			}
 
 			switch s[0] {
@@ -220,22 +312,25 @@ func parseMessageResponse(body io.Reader) (*MessageResponse, error) {
 				response.AccountPoint, _ = strconv.Atoi(s[1])
 			case "Duplicate":
 				response.Duplicate = Ptr(s[1])
+				result.AlreadySent = s[1] == "Y"
 			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return err
 	}
-	if result == nil {
-		return nil, &UnexpectedResponseError{Reason: "invalid response"}
+	if result != nil {
+		yield(result)
 	}
-	return response, nil
+	return nil
 }
 
 // MessageStatusParams represents parameters of query message status.
 type MessageStatusParams struct {
 	MessageIDs         []string
 	HideDeductedPoints bool
+	BatchSize          int // Maximum number of msgids per request, defaults to MaxQueryMessageIDs
+	Concurrency        int // Maximum number of concurrent requests, defaults to defaultConcurrency
 }
 
 // MessageStatus represents status of message.
@@ -247,13 +342,52 @@ type MessageStatus struct {
 // MessageStatusResponse represents response of query message status.
 type MessageStatusResponse struct {
 	Statuses []*MessageStatus
+	NotFound []string // The msgids Mitake returned no data for
 }
 
 // QueryMessageStatus fetch the status of specific messages.
+//
+// Duplicate msgids are removed, and the remaining msgids are split into chunks of
+// at most BatchSize msgids, which are queried concurrently and merged in order.
 func (c *Client) QueryMessageStatus(ctx context.Context, params MessageStatusParams) (*MessageStatusResponse, error) {
+	ids := dedupeStrings(params.MessageIDs)
+	chunks := chunkStrings(ids, params.BatchSize, MaxQueryMessageIDs)
+	responses := make([]*MessageStatusResponse, len(chunks))
+
+	err := forEachChunk(ctx, len(chunks), params.Concurrency, func(ctx context.Context, i int) error {
+		resp, err := c.queryMessageStatus(ctx, chunks[i], params.HideDeductedPoints)
+		if err != nil {
+			return err
+		}
+		responses[i] = resp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(MessageStatusResponse)
+	found := make(map[string]bool, len(ids))
+	for _, resp := range responses {
+		for _, status := range resp.Statuses {
+			response.Statuses = append(response.Statuses, status)
+			if status.StatusCode != StatusNoDataFound {
+				found[status.Msgid] = true
+			}
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			response.NotFound = append(response.NotFound, id)
+		}
+	}
+	return response, nil
+}
+
+func (c *Client) queryMessageStatus(ctx context.Context, messageIDs []string, hideDeductedPoints bool) (*MessageStatusResponse, error) {
 	q := c.buildDefaultQuery()
-	q.Set("msgid", strings.Join(params.MessageIDs, ","))
-	if !params.HideDeductedPoints {
+	q.Set("msgid", strings.Join(messageIDs, ","))
+	if !hideDeductedPoints {
 		q.Set("smsPointFlag", "1")
 	}
 
@@ -276,14 +410,22 @@ func parseMessageStatusResponse(body io.Reader) (*MessageStatusResponse, error)
 	)
 	for scanner.Scan() {
 		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
 
 		s := strings.Split(text, "\t")
+		if len(s) < 2 {
+			return nil, &UnexpectedResponseError{Reason: "invalid message status"}
+		}
 		messageStatus := &MessageStatus{
 			MessageResult: MessageResult{
 				Msgid:      s[0],
 				StatusCode: StatusCode(s[1]),
 			},
-			StatusTime: s[2],
+		}
+		if len(s) >= 3 {
+			messageStatus.StatusTime = s[2]
 		}
 		if len(s) == 4 {
 			point, _ := strconv.Atoi(s[3])
@@ -345,11 +487,17 @@ func parseCancelScheduledMessagesResponse(body io.Reader) ([]*CanceledMessage, e
 	)
 	for scanner.Scan() {
 		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
 
-		s := strings.Split(text, "=")
+		msgid, status, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, &UnexpectedResponseError{Reason: "invalid cancel status"}
+		}
 		messages = append(messages, &CanceledMessage{
-			Msgid:      s[0],
-			StatusCode: StatusCode(s[1]),
+			Msgid:      msgid,
+			StatusCode: StatusCode(status),
 		})
 	}
 	if err := scanner.Err(); err != nil {
@@ -357,3 +505,79 @@ func parseCancelScheduledMessagesResponse(body io.Reader) ([]*CanceledMessage, e
 	}
 	return messages, nil
 }
+
+// CancelParams represents parameters of cancel scheduled messages.
+type CancelParams struct {
+	MessageIDs  []string
+	ObjectID    string // Cancel every message recorded for this batch by the client's ObjectRegistry
+	BatchSize   int    // Maximum number of msgids per request, defaults to MaxQueryMessageIDs
+	Concurrency int    // Maximum number of concurrent requests, defaults to defaultConcurrency
+}
+
+// CancelResponse represents the grouped results of cancel scheduled messages.
+type CancelResponse struct {
+	Canceled    []*CanceledMessage // Reservation canceled
+	AlreadySent []*CanceledMessage // The message has already left the reservation queue
+	NotFound    []*CanceledMessage // Mitake has no data for the msgid
+	Failed      []*CanceledMessage // Any other status, such as a service error
+}
+
+// CancelMessages cancels scheduled messages in chunks, and groups the results by
+// outcome. The msgids to cancel are taken from MessageIDs, plus the msgids recorded
+// for ObjectID when it is set.
+func (c *Client) CancelMessages(ctx context.Context, params CancelParams) (*CancelResponse, error) {
+	ids := params.MessageIDs
+	if params.ObjectID != "" {
+		if c.Objects == nil {
+			return nil, &ParameterError{Reason: "ObjectID requires an ObjectRegistry"}
+		}
+		recorded, err := c.Objects.MessageIDs(ctx, params.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(append([]string(nil), ids...), recorded...)
+	}
+	ids = dedupeStrings(ids)
+	if len(ids) == 0 {
+		return nil, &ParameterError{Reason: "empty MessageIDs"}
+	}
+
+	chunks := chunkStrings(ids, params.BatchSize, MaxQueryMessageIDs)
+	results := make([][]*CanceledMessage, len(chunks))
+
+	err := forEachChunk(ctx, len(chunks), params.Concurrency, func(ctx context.Context, i int) error {
+		messages, err := c.CancelScheduledMessages(ctx, chunks[i])
+		if err != nil {
+			return err
+		}
+		results[i] = messages
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(CancelResponse)
+	seen := make(map[string]bool, len(ids))
+	for _, messages := range results {
+		for _, message := range messages {
+			seen[message.Msgid] = true
+			switch {
+			case message.StatusCode == StatusReservationCanceled:
+				response.Canceled = append(response.Canceled, message)
+			case message.StatusCode == StatusNoDataFound:
+				response.NotFound = append(response.NotFound, message)
+			case message.StatusCode.IsSent():
+				response.AlreadySent = append(response.AlreadySent, message)
+			default:
+				response.Failed = append(response.Failed, message)
+			}
+		}
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			response.NotFound = append(response.NotFound, &CanceledMessage{Msgid: id, StatusCode: StatusNoDataFound})
+		}
+	}
+	return response, nil
+}
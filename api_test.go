@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -454,6 +455,55 @@ func TestBatchMessageOptions_Validate(t *testing.T) {
 			},
 			expectedError: &ParameterError{Reason: "1: [1aab] empty Smbody"},
 		},
+		{
+			params: BatchMessagesParams{
+				Messages: []Message{
+					{
+						ClientID: "0aab",
+						Dstaddr:  "0987654321",
+						Smbody:   "Pay $$100",
+					},
+				},
+			},
+			expectedError: &ParameterError{Reason: `0: [0aab] Smbody contains field separator "$$"`},
+		},
+		{
+			params: BatchMessagesParams{
+				Messages: []Message{
+					{
+						ClientID: "0aab",
+						Dstaddr:  "0987654321",
+						Destname: "Bob\r\n1aab",
+						Smbody:   "Test1",
+					},
+				},
+			},
+			expectedError: &ParameterError{Reason: "0: [0aab] Destname contains line break"},
+		},
+		{
+			params: BatchMessagesParams{
+				Messages: []Message{
+					{
+						ClientID: "0aab",
+						Dstaddr:  "0987654321",
+						Destname: "Bob$",
+						Smbody:   "Test1",
+					},
+				},
+			},
+			expectedError: &ParameterError{Reason: `0: [0aab] Destname ends with "$"`},
+		},
+		{
+			params: BatchMessagesParams{
+				Messages: []Message{
+					{
+						ClientID: "0aab",
+						Dstaddr:  "0987654321",
+						Smbody:   "Line1\nLine2 costs $",
+					},
+				},
+			},
+		},
 		{
 			params: BatchMessagesParams{
 				Messages: []Message{
@@ -518,6 +568,18 @@ func TestBatchMessageOptions_ToData(t *testing.T) {
 			},
 			expected: "0aab$$0987654321$$20170101010000$$20170101012300$$Bob$$https://example.com/callback$$Test1\r\n",
 		},
+		{
+			params: BatchMessagesParams{
+				Messages: []Message{
+					{
+						ClientID: "0aab",
+						Dstaddr:  "0987654321",
+						Smbody:   "Line1\r\nLine2\nLine3",
+					},
+				},
+			},
+			expected: "0aab$$0987654321$$$$$$$$$$Line1\x06Line2\x06Line3\r\n",
+		},
 	}
 	for i, tc := range testCases {
 		t.Run(fmt.Sprintf("case=%d", i), func(t *testing.T) {
@@ -787,7 +849,7 @@ bar`),
 			body: strings.NewReader("[foo]"),
 			expectedResponse: &MessageResponse{
 				Results: []*MessageResult{
-					{},
+					{ClientID: "foo"},
 				},
 			},
 		},
@@ -802,9 +864,11 @@ smsPoint=1
 			expectedResponse: &MessageResponse{
 				Results: []*MessageResult{
 					{
-						Msgid:      "#000000333",
-						StatusCode: StatusCode("0"),
-						SmsPoint:   Ptr(1),
+						ClientID:    "0",
+						Msgid:       "#000000333",
+						StatusCode:  StatusCode("0"),
+						SmsPoint:    Ptr(1),
+						AlreadySent: true,
 					},
 				},
 				AccountPoint: 92,
@@ -822,10 +886,12 @@ AccountPoint=92`),
 			expectedResponse: &MessageResponse{
 				Results: []*MessageResult{
 					{
+						ClientID:   "0",
 						Msgid:      "#000000333",
 						StatusCode: StatusCode("0"),
 					},
 					{
+						ClientID:   "1",
 						Msgid:      "#000000334",
 						StatusCode: StatusCode("1"),
 					},
@@ -863,7 +929,7 @@ func TestClient_QueryMessageStatus(t *testing.T) {
 			params: MessageStatusParams{MessageIDs: []string{"1010079522", "1010079523"}},
 			response: `1010079522	1	20170101010010	1
 1010079523	4	20170101010011	1`,
-			expectedRequestURI: "/b2c/mtk/SmQuery?msgid=1010079522%2C1010079523&smsPointFlag=1",
+			expectedRequestURI: "/b2c/mtk/SmQuery?msgid=1010079522%2C1010079523&password=password&smsPointFlag=1&username=username",
 			expectedData:       "1010079522,1010079523",
 			expectedResponse: &MessageStatusResponse{
 				Statuses: []*MessageStatus{
@@ -890,7 +956,7 @@ func TestClient_QueryMessageStatus(t *testing.T) {
 			name:               "hide deducted points",
 			params:             MessageStatusParams{MessageIDs: []string{"1010079522"}, HideDeductedPoints: true},
 			response:           `1010079522	1	20170101010010`,
-			expectedRequestURI: "/b2c/mtk/SmQuery?msgid=1010079522",
+			expectedRequestURI: "/b2c/mtk/SmQuery?msgid=1010079522&password=password&username=username",
 			expectedData:       "1010079522,1010079523",
 			expectedResponse: &MessageStatusResponse{
 				Statuses: []*MessageStatus{
@@ -928,12 +994,61 @@ func TestClient_QueryMessageStatus(t *testing.T) {
 	}
 }
 
+func TestClient_QueryMessageStatus_pagination(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var requests int32
+	mux.HandleFunc("/b2c/mtk/SmQuery", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testCredentials(t, r)
+		atomic.AddInt32(&requests, 1)
+
+		for _, id := range strings.Split(r.URL.Query().Get("msgid"), ",") {
+			if id == "3" {
+				_, _ = fmt.Fprintf(w, "%s\tz\n", id)
+				continue
+			}
+			if id == "4" {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "%s\t4\t20170101010010\n", id)
+		}
+	})
+
+	resp, err := client.QueryMessageStatus(context.Background(), MessageStatusParams{
+		MessageIDs:         []string{"1", "2", "1", "3", "4", "5"},
+		HideDeductedPoints: true,
+		BatchSize:          2,
+		Concurrency:        2,
+	})
+	if err != nil {
+		t.Fatalf("QueryMessageStatus returned unexpected error: %v", err)
+	}
+
+	if got, want := atomic.LoadInt32(&requests), int32(3); got != want {
+		t.Errorf("QueryMessageStatus made %d requests, want %d", got, want)
+	}
+
+	var msgids []string
+	for _, status := range resp.Statuses {
+		msgids = append(msgids, status.Msgid)
+	}
+	if want := []string{"1", "2", "3", "5"}; !reflect.DeepEqual(msgids, want) {
+		t.Errorf("QueryMessageStatus returned statuses for %v, want %v", msgids, want)
+	}
+	if want := []string{"3", "4"}; !reflect.DeepEqual(resp.NotFound, want) {
+		t.Errorf("QueryMessageStatus returned NotFound %v, want %v", resp.NotFound, want)
+	}
+}
+
 func TestClient_QueryAccountPoint(t *testing.T) {
 	client, mux, teardown := setup()
 	defer teardown()
 
 	mux.HandleFunc("/b2c/mtk/SmQuery", func(w http.ResponseWriter, r *http.Request) {
 		testMethod(t, r, "GET")
+		testCredentials(t, r)
 		_, _ = fmt.Fprint(w, `AccountPoint=100`)
 	})
 
@@ -952,6 +1067,7 @@ func TestClient_CancelScheduledMessages(t *testing.T) {
 
 	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
 		testMethod(t, r, "GET")
+		testCredentials(t, r)
 		_, _ = fmt.Fprint(w, `1010079522=8
 1010079523=9`)
 	})
@@ -975,3 +1091,96 @@ func TestClient_CancelScheduledMessages(t *testing.T) {
 		t.Errorf("CancelScheduledMessages returned %+v, want %+v", resp, want)
 	}
 }
+
+func TestParseCancelScheduledMessagesResponse(t *testing.T) {
+	resp, err := parseCancelScheduledMessagesResponse(strings.NewReader("#1=9\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("parseCancelScheduledMessagesResponse returned unexpected error: %v", err)
+	}
+	if want := []*CanceledMessage{{Msgid: "#1", StatusCode: StatusCode("9")}}; !reflect.DeepEqual(resp, want) {
+		t.Errorf("parseCancelScheduledMessagesResponse returned %+v, want %+v", resp, want)
+	}
+
+	_, err = parseCancelScheduledMessagesResponse(strings.NewReader("#1=9\r\n<html>\r\n"))
+	if want := (&UnexpectedResponseError{Reason: "invalid cancel status"}); !errors.Is(err, want) {
+		t.Errorf("parseCancelScheduledMessagesResponse returned error %v, want %v", err, want)
+	}
+}
+
+func TestClient_CancelMessages(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.Objects = NewMemoryObjectRegistry()
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[0aab]
+msgid=1010079522
+statuscode=0
+[1aab]
+msgid=1010079523
+statuscode=0
+AccountPoint=98`)
+	})
+	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testCredentials(t, r)
+		for _, id := range strings.Split(r.URL.Query().Get("msgid"), ",") {
+			switch id {
+			case "1010079522":
+				_, _ = fmt.Fprintf(w, "%s=9\n", id)
+			case "1010079523":
+				_, _ = fmt.Fprintf(w, "%s=4\n", id)
+			case "1010079524":
+				_, _ = fmt.Fprintf(w, "%s=z\n", id)
+			case "1010079525":
+				_, _ = fmt.Fprintf(w, "%s=*\n", id)
+			}
+		}
+	})
+
+	_, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		ObjectID: "campaign1",
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1", Dlvtime: "20370101010000"},
+			{ClientID: "1aab", Dstaddr: "0987654322", Smbody: "Test2", Dlvtime: "20370101010000"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	resp, err := client.CancelMessages(context.Background(), CancelParams{
+		MessageIDs: []string{"1010079524", "1010079525", "1010079526"},
+		ObjectID:   "campaign1",
+		BatchSize:  2,
+	})
+	if err != nil {
+		t.Fatalf("CancelMessages returned unexpected error: %v", err)
+	}
+
+	want := &CancelResponse{
+		Canceled:    []*CanceledMessage{{Msgid: "1010079522", StatusCode: StatusReservationCanceled}},
+		AlreadySent: []*CanceledMessage{{Msgid: "1010079523", StatusCode: StatusDelivered}},
+		NotFound: []*CanceledMessage{
+			{Msgid: "1010079524", StatusCode: StatusNoDataFound},
+			{Msgid: "1010079526", StatusCode: StatusNoDataFound},
+		},
+		Failed: []*CanceledMessage{{Msgid: "1010079525", StatusCode: StatusServiceError}},
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("CancelMessages returned %+v, want %+v", resp, want)
+	}
+}
+
+func TestClient_CancelMessages_withoutObjectRegistry(t *testing.T) {
+	client, _, teardown := setup()
+	defer teardown()
+
+	_, err := client.CancelMessages(context.Background(), CancelParams{ObjectID: "campaign1"})
+
+	expectedErr := &ParameterError{Reason: "ObjectID requires an ObjectRegistry"}
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("CancelMessages return error %v, want %v", err, expectedErr)
+	}
+}
@@ -0,0 +1,270 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is rejected by an open CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+// List of circuit states.
+const (
+	CircuitClosed   CircuitState = iota // Calls are allowed
+	CircuitOpen                         // Calls fail fast until OpenTimeout has passed
+	CircuitHalfOpen                     // A limited number of probe calls are allowed
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// CircuitBreaker tracks the health of a dependency. It opens after
+// FailureThreshold consecutive failures, rejects calls for OpenTimeout, and then
+// lets HalfOpenRequests probe calls through. A successful probe closes the
+// circuit, and a failed one opens it again. A probe whose outcome is not reported
+// within OpenTimeout is given up, so that another call can probe.
+//
+// The zero value is ready to use with the default settings.
+type CircuitBreaker struct {
+	FailureThreshold int           // Consecutive failures that open the circuit, defaults to 5
+	OpenTimeout      time.Duration // How long the circuit stays open, defaults to 30s
+	HalfOpenRequests int           // Probe calls allowed while half-open, defaults to 1
+
+	// OnStateChange, when set, is called after every state change, for alerting.
+	// It must not call the methods of the CircuitBreaker.
+	OnStateChange func(from, to CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+	probedAt time.Time        // Time of the last probe allowed
+	now      func() time.Time // For testing
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	return b.state
+}
+
+// Allow reports whether a call may proceed, and returns ErrCircuitOpen if not.
+// Every allowed call must be followed by Success or Failure.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		limit := b.HalfOpenRequests
+		if limit <= 0 {
+			limit = 1
+		}
+		if b.probes >= limit && b.clock().Sub(b.probedAt) < b.openTimeout() {
+			return ErrCircuitOpen
+		}
+		if b.probes >= limit {
+			b.probes = 0
+		}
+		b.probes++
+		b.probedAt = b.clock()
+	}
+	return nil
+}
+
+// Success records a successful call.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state == CircuitHalfOpen {
+		b.setState(CircuitClosed)
+	}
+}
+
+// Failure records a failed call.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	threshold := b.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= threshold) {
+		b.openedAt = b.clock()
+		b.setState(CircuitOpen)
+	}
+}
+
+// release gives back the slot of an allowed call whose outcome says nothing about
+// the health of the dependency, such as a call canceled by the caller.
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// expire moves an open circuit to half-open once OpenTimeout has passed.
+func (b *CircuitBreaker) expire() {
+	if b.state == CircuitOpen && b.clock().Sub(b.openedAt) >= b.openTimeout() {
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return defaultOpenTimeout
+	}
+	return b.OpenTimeout
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	from := b.state
+	b.state = state
+	b.probes = 0
+	if state == CircuitClosed {
+		b.failures = 0
+	}
+	if b.OnStateChange != nil && from != state {
+		b.OnStateChange(from, state)
+	}
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// isServiceFailureLine reports whether a line of a response carries a status code
+// showing that Mitake itself is degraded: the "statuscode=*" lines of the send
+// responses, the "msgid=*" lines of the cancel responses and the "msgid<TAB>*"
+// lines of the status responses. StatusReachedMaxConcurrentConnections is not a
+// failure, since it only shows that the caller sends too much at once.
+func isServiceFailureLine(line string) bool {
+	var code string
+	if key, value, ok := strings.Cut(line, "="); ok {
+		switch key {
+		case "msgid", "AccountPoint", "smsPoint", "Duplicate":
+			return false
+		}
+		code = value
+	} else if fields := strings.Split(line, "\t"); len(fields) >= 2 {
+		code = fields[1]
+	}
+	switch StatusCode(code) {
+	case StatusServiceError, StatusSMSTemporarilyUnavailable, StatusSMSTemporarilyUnavailableB, StatusServiceTemporarilyUnavailable:
+		return true
+	}
+	return false
+}
+
+// doWithBreaker sends the request through do, guarded by the breaker. Transport
+// errors and unexpected responses count as failures at once. Otherwise the outcome
+// is decided by the status codes read from the body, once it is read to the end
+// or closed.
+func doWithBreaker(b *CircuitBreaker, req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if err := b.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			b.release()
+		} else {
+			b.Failure()
+		}
+		return nil, err
+	}
+	if err := checkErrorResponse(resp); err != nil {
+		b.Failure()
+		resp.Body.Close()
+		return resp, err
+	}
+	resp.Body = &breakerBody{ReadCloser: resp.Body, breaker: b}
+	return resp, nil
+}
+
+// breakerBody reports to the breaker, at EOF or on Close, whether the body
+// contained a service failure status code.
+type breakerBody struct {
+	io.ReadCloser
+	breaker *CircuitBreaker
+	line    []byte
+	failed  bool
+	once    sync.Once
+}
+
+func (b *breakerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	for _, c := range p[:n] {
+		if c == '\n' {
+			b.checkLine()
+			continue
+		}
+		// Status lines are short, longer lines are only kept to their prefix.
+		if len(b.line) < 32 {
+			b.line = append(b.line, c)
+		}
+	}
+	if err == io.EOF {
+		b.report()
+	}
+	return n, err
+}
+
+func (b *breakerBody) checkLine() {
+	if isServiceFailureLine(strings.TrimSpace(string(b.line))) {
+		b.failed = true
+	}
+	b.line = b.line[:0]
+}
+
+func (b *breakerBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.report()
+	return err
+}
+
+// report reports the outcome of the call to the breaker, once.
+func (b *breakerBody) report() {
+	b.once.Do(func() {
+		b.checkLine()
+		if b.failed {
+			b.breaker.Failure()
+		} else {
+			b.breaker.Success()
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	var transitions []string
+	b := &CircuitBreaker{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+		now: func() time.Time { return now },
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow returned unexpected error: %v", err)
+		}
+		b.Failure()
+	}
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State returned %v, want %v", got, CircuitOpen)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow returned error %v, want %v", err, ErrCircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow returned unexpected error for the probe: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow returned error %v for a second probe, want %v", err, ErrCircuitOpen)
+	}
+	b.Failure()
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State returned %v after a failed probe, want %v", got, CircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow returned unexpected error for the probe: %v", err)
+	}
+	b.Success()
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State returned %v after a successful probe, want %v", got, CircuitClosed)
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	if !reflect.DeepEqual(transitions, want) {
+		t.Errorf("OnStateChange was called with %v, want %v", transitions, want)
+	}
+}
+
+func TestCircuitBreaker_successResetsFailures(t *testing.T) {
+	b := &CircuitBreaker{FailureThreshold: 2}
+
+	b.Failure()
+	b.Success()
+	b.Failure()
+
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("State returned %v, want %v", got, CircuitClosed)
+	}
+}
+
+func TestClient_Breaker(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	status := "r"
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "[1]\nmsgid=#000000001\nstatuscode=%s\nAccountPoint=98\n", status)
+	})
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.Breaker = &CircuitBreaker{FailureThreshold: 2, OpenTimeout: time.Minute}
+	client.Breaker.now = func() time.Time { return now }
+
+	params := MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Test"}}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Send(context.Background(), params); err != nil {
+			t.Fatalf("Send returned unexpected error: %v", err)
+		}
+	}
+	if _, err := client.Send(context.Background(), params); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Send returned error %v, want %v", err, ErrCircuitOpen)
+	}
+
+	status = "1"
+	now = now.Add(time.Minute)
+	if _, err := client.Send(context.Background(), params); err != nil {
+		t.Fatalf("Send returned unexpected error for the probe: %v", err)
+	}
+	if got := client.Breaker.State(); got != CircuitClosed {
+		t.Errorf("State returned %v, want %v", got, CircuitClosed)
+	}
+}
+
+func TestClient_Breaker_transportError(t *testing.T) {
+	client, _, teardown := setup()
+	teardown()
+
+	client.Breaker = &CircuitBreaker{FailureThreshold: 1}
+
+	params := MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Test"}}
+	if _, err := client.Send(context.Background(), params); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Send returned error %v, want a transport error", err)
+	}
+	if _, err := client.Send(context.Background(), params); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Send returned error %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestClient_Breaker_queryMessageStatus(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmQuery", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "1010079522\t*\t20170101010000\n")
+	})
+	client.Breaker = &CircuitBreaker{FailureThreshold: 1}
+
+	_, _ = client.QueryMessageStatus(context.Background(), MessageStatusParams{MessageIDs: []string{"1010079522"}})
+	if got := client.Breaker.State(); got != CircuitOpen {
+		t.Errorf("State returned %v, want %v", got, CircuitOpen)
+	}
+}
+
+func TestCircuitBreaker_probeTimeout(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &CircuitBreaker{FailureThreshold: 1, OpenTimeout: time.Minute}
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow returned unexpected error for the probe: %v", err)
+	}
+	// The outcome of the probe is never reported, as with a body never closed.
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow returned error %v, want %v", err, ErrCircuitOpen)
+	}
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Errorf("Allow returned error %v after the probe timed out, want nil", err)
+	}
+}
+
+func Test_isServiceFailureLine(t *testing.T) {
+	testCases := []struct {
+		line string
+		want bool
+	}{
+		{"statuscode=*", true},
+		{"statuscode=l", false},
+		{"statuscode=1", false},
+		{"statuscode=e", false},
+		{"1010079522=a", true},
+		{"1010079522=9", false},
+		{"1010079522\tr\t20170101010000", true},
+		{"1010079522\t4\t20170101010000", false},
+		{"AccountPoint=100", false},
+		{"msgid=#000000001", false},
+		{"[1]", false},
+	}
+	for _, tc := range testCases {
+		if got := isServiceFailureLine(tc.line); got != tc.want {
+			t.Errorf("isServiceFailureLine(%q) returned %v, want %v", tc.line, got, tc.want)
+		}
+	}
+}
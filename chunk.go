@@ -0,0 +1,89 @@
+package mitake
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// MaxQueryMessageIDs is the maximum number of msgids Mitake accepts in a single
+	// query or cancel request.
+	MaxQueryMessageIDs = 100
+
+	defaultConcurrency = 4
+)
+
+// dedupeStrings returns s without empty and duplicate elements, preserving order.
+func dedupeStrings(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	result := make([]string, 0, len(s))
+	for _, v := range s {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// chunkStrings splits s into chunks of at most size elements. A non-positive size
+// or one exceeding limit is replaced by limit.
+func chunkStrings(s []string, size, limit int) [][]string {
+	if size <= 0 || size > limit {
+		size = limit
+	}
+	var chunks [][]string
+	for len(s) > size {
+		chunks = append(chunks, s[:size:size])
+		s = s[size:]
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
+
+// forEachChunk calls fn for each index in [0, n) with at most concurrency calls in
+// flight. It returns the first error, after which the context passed to the
+// remaining calls is canceled.
+func forEachChunk(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, concurrency)
+	)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			if firstErr != nil {
+				return firstErr
+			}
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,99 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_dedupeStrings(t *testing.T) {
+	actual := dedupeStrings([]string{"1", "2", "", "1", "3", "2"})
+	want := []string{"1", "2", "3"}
+
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("dedupeStrings returned %v, want %v", actual, want)
+	}
+}
+
+func Test_chunkStrings(t *testing.T) {
+	testCases := []struct {
+		s        []string
+		size     int
+		limit    int
+		expected [][]string
+	}{
+		{
+			s:     nil,
+			limit: 2,
+		},
+		{
+			s:        []string{"1", "2", "3"},
+			limit:    2,
+			expected: [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			s:        []string{"1", "2", "3"},
+			size:     1,
+			limit:    2,
+			expected: [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			s:        []string{"1", "2", "3"},
+			size:     5,
+			limit:    2,
+			expected: [][]string{{"1", "2"}, {"3"}},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case=%d", i), func(t *testing.T) {
+			actual := chunkStrings(tc.s, tc.size, tc.limit)
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("chunkStrings returned %v, want %v", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func Test_forEachChunk(t *testing.T) {
+	var inFlight, maxInFlight, calls int32
+
+	err := forEachChunk(context.Background(), 10, 3, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("forEachChunk returned unexpected error: %v", err)
+	}
+	if calls != 10 {
+		t.Errorf("forEachChunk called fn %d times, want %d", calls, 10)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("forEachChunk ran %d calls concurrently, want at most %d", maxInFlight, 3)
+	}
+}
+
+func Test_forEachChunk_error(t *testing.T) {
+	want := errors.New("boom")
+
+	err := forEachChunk(context.Background(), 10, 1, func(ctx context.Context, i int) error {
+		if i == 2 {
+			return want
+		}
+		return nil
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("forEachChunk returned error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,122 @@
+package mitake
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ClientIDGenerator generates the ClientIDs of the messages sent without one.
+// Mitake accepts a ClientID only once, and answers a message with a ClientID it
+// has already accepted with the msgid of the first one, see MessageResult.AlreadySent.
+//
+// Example usage:
+//
+//	client.ClientIDs = mitake.UUIDv7Generator{}
+type ClientIDGenerator interface {
+	ClientID(message Message) string
+}
+
+// ClientIDFunc is an adapter to use an ordinary function as a ClientIDGenerator.
+type ClientIDFunc func(message Message) string
+
+// ClientID returns f(message).
+func (f ClientIDFunc) ClientID(message Message) string {
+	return f(message)
+}
+
+// UUIDv7Generator generates time-ordered UUIDs of version 7, as defined by RFC 9562.
+type UUIDv7Generator struct{}
+
+// ClientID returns a new UUIDv7.
+func (UUIDv7Generator) ClientID(Message) string {
+	return newUUIDv7(time.Now(), rand.Reader)
+}
+
+func newUUIDv7(t time.Time, random io.Reader) string {
+	var b [16]byte
+	_, _ = io.ReadFull(random, b[6:])
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16|uint64(binary.BigEndian.Uint16(b[6:8])))
+	b[6] = b[6]&0x0f | 0x70 // Version 7
+	b[8] = b[8]&0x3f | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ULIDGenerator generates ULIDs, time-ordered identifiers of 26 characters.
+type ULIDGenerator struct{}
+
+// ClientID returns a new ULID.
+func (ULIDGenerator) ClientID(Message) string {
+	return newULID(time.Now(), rand.Reader)
+}
+
+// crockfordBase32 is the alphabet of the ULIDs.
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID(t time.Time, random io.Reader) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	_, _ = io.ReadFull(random, b[6:])
+
+	// 26 characters of 5 bits encode the 128 bits, the first character holds 3 bits.
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var s [26]byte
+	for i := len(s) - 1; i >= 0; i-- {
+		s[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// ContentHashGenerator generates deterministic ClientIDs from the recipient,
+// the body and the delivery time of the messages, so that Mitake does not
+// accept the same message twice, even from different processes.
+type ContentHashGenerator struct {
+	// Salt, when set, is hashed with the messages, to tell apart the identical
+	// messages of different campaigns.
+	Salt string
+}
+
+// ClientID returns the hex SHA-256 of the message, truncated to 32 characters.
+func (g ContentHashGenerator) ClientID(message Message) string {
+	h := sha256.New()
+	for _, s := range []string{g.Salt, normalizePhoneNumber(message.Dstaddr), message.Dlvtime, message.Smbody} {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// newClientID returns a random ClientID, for the messages that must have one
+// when the client has no ClientIDs.
+func newClientID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// fillClientID sets the ClientID of the message with the ClientIDs of the client,
+// when it is empty.
+func (c *Client) fillClientID(message *Message) {
+	if message.ClientID == "" && c.ClientIDs != nil {
+		message.ClientID = c.ClientIDs.ClientID(*message)
+	}
+}
+
+// fillClientIDs returns the messages with their missing ClientIDs filled in,
+// copying them rather than changing the caller's slice.
+func (c *Client) fillClientIDs(messages []Message) []Message {
+	if c.ClientIDs == nil {
+		return messages
+	}
+	filled := make([]Message, len(messages))
+	for i, message := range messages {
+		c.fillClientID(&message)
+		filled[i] = message
+	}
+	return filled
+}
@@ -0,0 +1,115 @@
+package mitake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_newUUIDv7(t *testing.T) {
+	at := time.UnixMilli(0x0189_1234_5678)
+	got := newUUIDv7(at, bytes.NewReader(bytes.Repeat([]byte{0xff}, 10)))
+	if want := "01891234-5678-7fff-bfff-ffffffffffff"; got != want {
+		t.Errorf("newUUIDv7 returned %v, want %v", got, want)
+	}
+	if got := (UUIDv7Generator{}).ClientID(Message{}); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+		t.Errorf("UUIDv7Generator returned %v, want a UUIDv7", got)
+	}
+}
+
+func Test_newULID(t *testing.T) {
+	got := newULID(time.UnixMilli(1469918176385), bytes.NewReader(make([]byte, 10)))
+	if want := "01ARYZ6S410000000000000000"; got != want {
+		t.Errorf("newULID returned %v, want %v", got, want)
+	}
+	got = newULID(time.UnixMilli(0), bytes.NewReader(bytes.Repeat([]byte{0xff}, 10)))
+	if want := "0000000000ZZZZZZZZZZZZZZZZ"; got != want {
+		t.Errorf("newULID returned %v, want %v", got, want)
+	}
+}
+
+func TestContentHashGenerator(t *testing.T) {
+	g := ContentHashGenerator{}
+	a := g.ClientID(Message{Dstaddr: "0987654321", Smbody: "Hello"})
+	if b := g.ClientID(Message{Dstaddr: "+886 987-654-321", Smbody: "Hello"}); a != b {
+		t.Errorf("ContentHashGenerator returned %v and %v for the same message", a, b)
+	}
+	if len(a) != 32 {
+		t.Errorf("ContentHashGenerator returned %v, want 32 characters", a)
+	}
+	for _, m := range []Message{
+		{Dstaddr: "0987654321", Smbody: "Hello!"},
+		{Dstaddr: "0912345678", Smbody: "Hello"},
+		{Dstaddr: "0987654321", Smbody: "Hello", Dlvtime: "20260101000000"},
+	} {
+		if b := g.ClientID(m); a == b {
+			t.Errorf("ContentHashGenerator returned %v for different messages", a)
+		}
+	}
+	if b := (ContentHashGenerator{Salt: "campaign"}).ClientID(Message{Dstaddr: "0987654321", Smbody: "Hello"}); a == b {
+		t.Errorf("ContentHashGenerator ignored the Salt")
+	}
+}
+
+func TestClient_SendBatch_clientIDs(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.ClientIDs = ClientIDFunc(func(m Message) string { return "gen-" + m.Dstaddr })
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := "gen-0987654321$$0987654321$$$$$$$$$$Hello\r\n" +
+			"own$$0912345678$$$$$$$$$$Hello\r\n"
+		if got := string(body); got != want {
+			t.Errorf("SendBatch sent %q, want %q", got, want)
+		}
+		_, _ = fmt.Fprint(w, "[gen-0987654321]\nmsgid=#000000013\nstatuscode=1\n[own]\nmsgid=#000000012\nstatuscode=1\nDuplicate=Y\nAccountPoint=126\n")
+	})
+
+	messages := []Message{
+		{Dstaddr: "0987654321", Smbody: "Hello"},
+		{ClientID: "own", Dstaddr: "0912345678", Smbody: "Hello"},
+	}
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{Messages: messages})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+	if messages[0].ClientID != "" {
+		t.Errorf("SendBatch changed the messages of the caller")
+	}
+	if got := resp.Results[0]; got.ClientID != "gen-0987654321" || got.AlreadySent {
+		t.Errorf("SendBatch returned result %+v, want the generated ClientID", got)
+	}
+	if got := resp.Results[1]; got.ClientID != "own" || !got.AlreadySent {
+		t.Errorf("SendBatch returned result %+v, want already sent", got)
+	}
+}
+
+func TestClient_Send_clientIDs(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.ClientIDs = ContentHashGenerator{}
+	want := ContentHashGenerator{}.ClientID(Message{Dstaddr: "0987654321", Smbody: "Hello"})
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.PostFormValue("clientid"); got != want {
+			t.Errorf("Send sent clientid %v, want %v", got, want)
+		}
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\nDuplicate=Y\n")
+	})
+
+	resp, err := client.Send(context.Background(), MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if got := resp.Results[0]; got.ClientID != want || !got.AlreadySent {
+		t.Errorf("Send returned result %+v, want the generated ClientID, already sent", got)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/minchao/go-mitake/v2"
+)
+
+func newFlagSet(name, args string, env *environment) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(env.stderr, "Usage: mitake %s [options] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+var resultHeader = []string{"CLIENTID", "MSGID", "STATUS", "DESCRIPTION"}
+
+func resultRow(result *mitake.MessageResult) []string {
+	return []string{result.ClientID, result.Msgid, string(result.StatusCode), result.StatusCode.String()}
+}
+
+func runSend(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("send", "", env)
+	var params mitake.MessageParams
+	fs.StringVar(&params.Dstaddr, "t", "", "Destination phone number, for example: 0987654321")
+	fs.StringVar(&params.Smbody, "m", "", "Message content")
+	fs.StringVar(&params.ClientID, "c", "", "ClientID of the message")
+	fs.StringVar(&params.Destname, "n", "", "Destination receiver name")
+	fs.StringVar(&params.Dlvtime, "d", "", "Scheduled delivery time, format: YYYYMMDDHHMMSS")
+	fs.StringVar(&params.Vldtime, "v", "", "Validity period, format: YYYYMMDDHHMMSS, or a number of seconds")
+	fs.StringVar(&params.Response, "r", "", "Callback URL to receive the delivery receipt")
+	fs.StringVar(&params.ObjectID, "object", "", "Name of the batch")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+	if params.Dstaddr == "" || params.Smbody == "" {
+		return exitUsage, &usageError{reason: "-t and -m are required"}
+	}
+
+	client, err := env.client()
+	if err != nil {
+		return exitUsage, err
+	}
+	resp, err := client.Send(ctx, params)
+	if err != nil {
+		return exitError, err
+	}
+
+	var (
+		rows  [][]string
+		codes []mitake.StatusCode
+	)
+	for _, result := range resp.Results {
+		rows = append(rows, resultRow(result))
+		codes = append(codes, result.StatusCode)
+	}
+	if err := env.printer.print(resp, resultHeader, rows); err != nil {
+		return exitError, err
+	}
+	return worstExitCode(codes...), nil
+}
+
+func runBatch(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("batch", "[file]", env)
+	var (
+		format  string
+		columns string
+		opts    mitake.BatchMessagesParams
+	)
+	fs.StringVar(&format, "format", "", "Input format: csv or json, defaults to the file extension")
+	fs.StringVar(&columns, "columns", "", "CSV header of each field, for example: ClientID=id,Dstaddr=phone,Smbody=text")
+	fs.StringVar(&opts.ObjectID, "object", "", "Name of the batch")
+	fs.IntVar(&opts.BatchSize, "size", mitake.MaxBatchMessages, "Maximum number of messages per request")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+
+	var in io.Reader = env.stdin
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return exitError, err
+		}
+		defer f.Close()
+		in = f
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(name), ".")
+		}
+	}
+
+	var messages iter.Seq2[mitake.Message, error]
+	switch format {
+	case "csv":
+		cols, err := parseColumns(columns)
+		if err != nil {
+			return exitUsage, err
+		}
+		messages = mitake.NewCSVMessageReader(in, cols).Messages()
+	case "json":
+		messages = jsonMessages(in)
+	default:
+		return exitUsage, &usageError{reason: "-format must be csv or json"}
+	}
+
+	client, err := env.client()
+	if err != nil {
+		return exitUsage, err
+	}
+
+	if !env.printer.json {
+		_ = env.printer.printLine(nil, resultHeader)
+	}
+	code := exitOK
+	for result, err := range client.SendBatchFrom(ctx, opts, messages) {
+		if err != nil {
+			return exitError, err
+		}
+		if err := env.printer.printLine(result, resultRow(result)); err != nil {
+			return exitError, err
+		}
+		code = max(code, worstExitCode(result.StatusCode))
+	}
+	return code, nil
+}
+
+// parseColumns parses a list of field=header pairs into CSVColumns. The fields
+// default to the header names clientid, dstaddr and smbody.
+func parseColumns(s string) (mitake.CSVColumns, error) {
+	cols := mitake.CSVColumns{ClientID: "clientid", Dstaddr: "dstaddr", Smbody: "smbody"}
+	if s == "" {
+		return cols, nil
+	}
+	fields := map[string]*string{
+		"clientid": &cols.ClientID,
+		"dstaddr":  &cols.Dstaddr,
+		"smbody":   &cols.Smbody,
+		"dlvtime":  &cols.Dlvtime,
+		"vldtime":  &cols.Vldtime,
+		"destname": &cols.Destname,
+		"response": &cols.Response,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		p, known := fields[strings.ToLower(strings.TrimSpace(field))]
+		if !ok || !known {
+			return cols, &usageError{reason: fmt.Sprintf("invalid column %q", pair)}
+		}
+		*p = strings.TrimSpace(header)
+	}
+	return cols, nil
+}
+
+// jsonMessages returns an iterator over a JSON array of messages. The iteration
+// stops after the first error.
+func jsonMessages(r io.Reader) iter.Seq2[mitake.Message, error] {
+	return func(yield func(mitake.Message, error) bool) {
+		dec := json.NewDecoder(r)
+		if _, err := dec.Token(); err != nil {
+			yield(mitake.Message{}, fmt.Errorf("read JSON array: %w", err))
+			return
+		}
+		for dec.More() {
+			var message mitake.Message
+			if err := dec.Decode(&message); err != nil {
+				yield(mitake.Message{}, err)
+				return
+			}
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
+
+func runStatus(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("status", "msgid...", env)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+	if fs.NArg() == 0 {
+		return exitUsage, &usageError{reason: "msgid is required"}
+	}
+
+	client, err := env.client()
+	if err != nil {
+		return exitUsage, err
+	}
+	resp, err := client.QueryMessageStatus(ctx, mitake.MessageStatusParams{MessageIDs: fs.Args()})
+	if err != nil {
+		return exitError, err
+	}
+
+	var (
+		rows  [][]string
+		codes []mitake.StatusCode
+	)
+	for _, status := range resp.Statuses {
+		rows = append(rows, []string{status.Msgid, string(status.StatusCode), status.StatusCode.String(), status.StatusTime})
+		codes = append(codes, status.StatusCode)
+	}
+	for range resp.NotFound {
+		codes = append(codes, mitake.StatusNoDataFound)
+	}
+	if err := env.printer.print(resp, []string{"MSGID", "STATUS", "DESCRIPTION", "TIME"}, rows); err != nil {
+		return exitError, err
+	}
+	return worstExitCode(codes...), nil
+}
+
+func runBalance(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("balance", "", env)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+
+	client, err := env.client()
+	if err != nil {
+		return exitUsage, err
+	}
+	point, err := client.QueryAccountPoint(ctx)
+	if err != nil {
+		return exitError, err
+	}
+
+	v := struct {
+		AccountPoint int `json:"AccountPoint"`
+	}{point}
+	if err := env.printer.print(v, []string{"ACCOUNTPOINT"}, [][]string{{strconv.Itoa(point)}}); err != nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+func runCancel(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("cancel", "msgid...", env)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+	if fs.NArg() == 0 {
+		return exitUsage, &usageError{reason: "msgid is required"}
+	}
+
+	client, err := env.client()
+	if err != nil {
+		return exitUsage, err
+	}
+	resp, err := client.CancelMessages(ctx, mitake.CancelParams{MessageIDs: fs.Args()})
+	if err != nil {
+		return exitError, err
+	}
+
+	var rows [][]string
+	groups := []struct {
+		name     string
+		messages []*mitake.CanceledMessage
+	}{
+		{"canceled", resp.Canceled},
+		{"already sent", resp.AlreadySent},
+		{"not found", resp.NotFound},
+		{"failed", resp.Failed},
+	}
+	for _, group := range groups {
+		for _, message := range group.messages {
+			rows = append(rows, []string{message.Msgid, string(message.StatusCode), group.name})
+		}
+	}
+	if err := env.printer.print(resp, []string{"MSGID", "STATUS", "RESULT"}, rows); err != nil {
+		return exitError, err
+	}
+
+	code := exitOK
+	if len(resp.AlreadySent) > 0 || len(resp.NotFound) > 0 {
+		code = exitRequest
+	}
+	for _, message := range resp.Failed {
+		code = max(code, worstExitCode(message.StatusCode))
+	}
+	return code, nil
+}
+
+func runReceipts(ctx context.Context, env *environment, args []string) (int, error) {
+	fs := newFlagSet("receipts", "", env)
+	var (
+		addr string
+		path string
+	)
+	fs.StringVar(&addr, "addr", ":8080", "Address to listen on")
+	fs.StringVar(&path, "path", "/callback", "Path of the callback URL")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return exitError, err
+	}
+	_, _ = fmt.Fprintf(env.stderr, "listening on %s%s\n", ln.Addr(), path)
+
+	if !env.printer.json {
+		_ = env.printer.printLine(nil, []string{"MSGID", "DSTADDR", "STATUS", "DESCRIPTION", "DONETIME"})
+	}
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := mitake.ParseMessageReceipt(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		_ = env.printer.printLine(receipt, []string{
+			receipt.Msgid,
+			receipt.Dstaddr,
+			receipt.Statuscode,
+			receipt.Statusstring.String(),
+			receipt.Donetime,
+		})
+		mu.Unlock()
+	})
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return exitError, err
+	}
+	return exitOK, nil
+}
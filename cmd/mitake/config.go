@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/minchao/go-mitake/v2/config"
+)
+
+// loadConfig reads the config file, then applies the environment variables and
+// the credential flags on top of it.
+func loadConfig(file string, getenv func(string) string, flags config.Config) (*config.Config, error) {
+	cfg := new(config.Config)
+	if file != "" {
+		if err := cfg.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.LoadEnv(getenv); err != nil {
+		return nil, err
+	}
+	if flags.Username != "" {
+		cfg.Username = flags.Username
+	}
+	if flags.Password != "" {
+		cfg.Password = flags.Password
+	}
+	if flags.BaseURL != "" {
+		cfg.BaseURL = flags.BaseURL
+	}
+	return cfg, nil
+}
@@ -0,0 +1,216 @@
+// Command mitake sends and manages SMS through the Mitake API.
+//
+// Usage:
+//
+//	mitake [global options] <command> [options] [arguments]
+//
+// The commands are:
+//
+//	send      send a message
+//	batch     send messages from a CSV or JSON file
+//	status    query the status of messages
+//	balance   query the account balance
+//	cancel    cancel scheduled messages
+//	receipts  listen for delivery receipts and print them
+//
+// The credentials are read from the -u and -p flags, the MITAKE_USERNAME and
+// MITAKE_PASSWORD environment variables, or the config file, in that order. The
+// config file and the other environment variables are those of config.Load.
+//
+// The exit status is 0 on success, 2 on usage errors, and otherwise follows the
+// class of the worst status code returned by Mitake: 3 authentication, 4 account,
+// 5 service unavailable, 6 invalid request, and 7 delivery failure.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/minchao/go-mitake/v2"
+	"github.com/minchao/go-mitake/v2/config"
+)
+
+// Exit codes of the command.
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitUsage           = 2
+	exitAuthentication  = 3
+	exitAccount         = 4
+	exitUnavailable     = 5
+	exitRequest         = 6
+	exitDeliveryFailure = 7
+)
+
+// exitCode returns the exit code for a status class.
+func exitCode(class mitake.StatusClass) int {
+	switch class {
+	case mitake.StatusClassSuccess, mitake.StatusClassCanceled:
+		return exitOK
+	case mitake.StatusClassAuthentication:
+		return exitAuthentication
+	case mitake.StatusClassAccount:
+		return exitAccount
+	case mitake.StatusClassUnavailable:
+		return exitUnavailable
+	case mitake.StatusClassRequest, mitake.StatusClassSuppressed:
+		return exitRequest
+	case mitake.StatusClassDeliveryFailure:
+		return exitDeliveryFailure
+	}
+	return exitError
+}
+
+// worstExitCode returns the highest exit code of the status codes.
+func worstExitCode(codes ...mitake.StatusCode) int {
+	code := exitOK
+	for _, c := range codes {
+		if e := exitCode(c.Class()); e > code {
+			code = e
+		}
+	}
+	return code
+}
+
+// usageError is returned by commands for invalid arguments.
+type usageError struct {
+	reason string
+}
+
+func (e *usageError) Error() string {
+	return e.reason
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, env *environment, args []string) (int, error)
+}
+
+var commands = []command{
+	{"send", "send a message", runSend},
+	{"batch", "send messages from a CSV or JSON file", runBatch},
+	{"status", "query the status of messages", runStatus},
+	{"balance", "query the account balance", runBalance},
+	{"cancel", "cancel scheduled messages", runCancel},
+	{"receipts", "listen for delivery receipts and print them", runReceipts},
+}
+
+// environment holds the global options shared by the commands.
+type environment struct {
+	config  *config.Config
+	dryRun  bool
+	printer *printer
+	stdin   io.Reader
+	stderr  io.Writer
+}
+
+func (env *environment) client() (*mitake.Client, error) {
+	if env.config.Username == "" || env.config.Password == "" {
+		return nil, &usageError{reason: "username and password are required"}
+	}
+	client, err := config.NewClient(env.config)
+	if err != nil {
+		return nil, err
+	}
+	if env.dryRun {
+		client.DryRun = mitake.NewDryRun()
+	}
+	return client, nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mitake", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: mitake [global options] <command> [options] [arguments]")
+		_, _ = fmt.Fprintln(stderr, "\nCommands:")
+		for _, cmd := range commands {
+			_, _ = fmt.Fprintf(stderr, "  %-9s %s\n", cmd.name, cmd.usage)
+		}
+		_, _ = fmt.Fprintln(stderr, "\nGlobal options:")
+		fs.PrintDefaults()
+		_, _ = fmt.Fprintln(stderr, "\nExit status:")
+		_, _ = fmt.Fprintln(stderr, "  0 success, 1 error, 2 usage error, 3 authentication, 4 account,")
+		_, _ = fmt.Fprintln(stderr, "  5 service unavailable, 6 invalid request, 7 delivery failure")
+	}
+
+	var (
+		configFile string
+		flags      config.Config
+		output     string
+		dryRun     bool
+	)
+	fs.StringVar(&configFile, "config", os.Getenv("MITAKE_CONFIG"), "Config file in JSON, YAML or TOML")
+	fs.StringVar(&flags.Username, "u", "", "Username, defaults to $MITAKE_USERNAME")
+	fs.StringVar(&flags.Password, "p", "", "Password, defaults to $MITAKE_PASSWORD")
+	fs.StringVar(&flags.BaseURL, "base-url", "", "Base URL of the Mitake API")
+	fs.StringVar(&output, "o", "table", "Output format: table or json")
+	fs.BoolVar(&dryRun, "dry-run", false, "Validate and print the results without sending any request")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(configFile, os.Getenv, flags)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	p, err := newPrinter(stdout, output)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	env := &environment{config: cfg, dryRun: dryRun, printer: p, stdin: stdin, stderr: stderr}
+
+	name := fs.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		code, err := cmd.run(ctx, env, fs.Args()[1:])
+		var (
+			ue *usageError
+			pe *mitake.ParameterError
+		)
+		switch {
+		case err == nil:
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &ue):
+			_, _ = fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		case code == exitUsage:
+			// The flag package has already reported the error.
+		case errors.As(err, &pe):
+			_, _ = fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return exitRequest
+		default:
+			_, _ = fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		}
+		return code
+	}
+
+	_, _ = fmt.Fprintf(stderr, "mitake: unknown command %q\n", name)
+	fs.Usage()
+	return exitUsage
+}
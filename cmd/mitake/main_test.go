@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/minchao/go-mitake/v2"
+	"github.com/minchao/go-mitake/v2/config"
+)
+
+func setup(t *testing.T) (mux *http.ServeMux, args []string) {
+	mux = http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return mux, []string{"-u", "username", "-p", "password", "-base-url", server.URL}
+}
+
+func TestRun_send(t *testing.T) {
+	testCases := []struct {
+		statusCode string
+		expected   int
+	}{
+		{"1", exitOK},
+		{"e", exitAuthentication},
+		{"f", exitAccount},
+		{"a", exitUnavailable},
+		{"v", exitRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.statusCode, func(t *testing.T) {
+			mux, args := setup(t)
+			mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprintf(w, "[1]\nmsgid=#000000013\nstatuscode=%s\nAccountPoint=126\n", tc.statusCode)
+			})
+
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), append(args, "send", "-t", "0987654321", "-m", "Hello"), nil, &stdout, &stderr)
+
+			if code != tc.expected {
+				t.Errorf("run returned %d, want %d, stderr: %s", code, tc.expected, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), "#000000013") {
+				t.Errorf("run printed %q, want the msgid", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_batch(t *testing.T) {
+	mux, args := setup(t)
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[0aab]\nmsgid=#1\nstatuscode=1\n[1aab]\nmsgid=#2\nstatuscode=1\nAccountPoint=98\n")
+	})
+
+	stdin := strings.NewReader(`[{"ClientID":"0aab","Dstaddr":"0987654321","Smbody":"Test1"},{"clientid":"1aab","dstaddr":"0987654322","smbody":"Test2"}]`)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append(args, "-o", "json", "batch", "-format", "json"), stdin, &stdout, &stderr)
+
+	if code != exitOK {
+		t.Errorf("run returned %d, want %d, stderr: %s", code, exitOK, stderr.String())
+	}
+	if got := strings.Count(stdout.String(), "\n"); got != 2 {
+		t.Errorf("run printed %d lines, want %d: %s", got, 2, stdout.String())
+	}
+}
+
+func TestRun_credentials(t *testing.T) {
+	testCases := []struct {
+		path string
+		body string
+		args []string
+	}{
+		{"/b2c/mtk/SmQuery", "AccountPoint=100", []string{"balance"}},
+		{"/b2c/mtk/SmQuery", "#1\t4\t20060810125612\n", []string{"status", "#1"}},
+		{"/b2c/mtk/SmCancel", "#1=9\n", []string{"cancel", "#1"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.args[0], func(t *testing.T) {
+			mux, args := setup(t)
+			mux.HandleFunc(tc.path, func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				if q.Get("username") != "username" || q.Get("password") != "password" {
+					t.Errorf("%s sent query %v, want the credentials", tc.args[0], q)
+				}
+				_, _ = fmt.Fprint(w, tc.body)
+			})
+
+			var stdout, stderr bytes.Buffer
+			if code := run(context.Background(), append(args, tc.args...), nil, &stdout, &stderr); code != exitOK {
+				t.Errorf("run returned %d, want %d, stderr: %s", code, exitOK, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRun_usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run(context.Background(), []string{"unknown"}, nil, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run returned %d, want %d", code, exitUsage)
+	}
+	if code := run(context.Background(), []string{"send", "-t", "0987654321"}, nil, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run returned %d, want %d", code, exitUsage)
+	}
+}
+
+func Test_loadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mitake.json")
+	if err := os.WriteFile(file, []byte(`{"username":"file","password":"file","base_url":"https://example.com/"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"MITAKE_PASSWORD": "env"}
+
+	cfg, err := loadConfig(file, func(key string) string { return env[key] }, config.Config{Username: "flag"})
+	if err != nil {
+		t.Fatalf("loadConfig returned unexpected error: %v", err)
+	}
+
+	want := &config.Config{Username: "flag", Password: "env", BaseURL: "https://example.com/"}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("loadConfig returned %+v, want %+v", cfg, want)
+	}
+}
+
+func Test_exitCode(t *testing.T) {
+	if got := worstExitCode(mitake.StatusDelivered, mitake.StatusPhoneNumberError, mitake.StatusInvalidPhoneNumber); got != exitDeliveryFailure {
+		t.Errorf("worstExitCode returned %d, want %d", got, exitDeliveryFailure)
+	}
+}
@@ -0,0 +1,347 @@
+// Package config loads the settings of a mitake.Client from JSON, YAML or TOML
+// files and from the environment. It is a package of its own, so that the
+// programs that configure the client in code do not depend on the YAML and TOML
+// parsers.
+//
+// Example usage:
+//
+//	cfg, err := config.Load("mitake.yaml")
+//	if err != nil { ... }
+//	client, err := config.NewClient(cfg)
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/minchao/go-mitake/v2"
+)
+
+// Duration is a time.Duration read from configuration files as a string, such as
+// "30s" or "5m".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalYAML decodes a duration from YAML.
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+// Config holds the settings of a Client, as read from a file and the environment
+// by Load.
+type Config struct {
+	Username string `json:"username" yaml:"username" toml:"username"`
+	Password string `json:"password" yaml:"password" toml:"password"`
+	BaseURL  string `json:"base_url" yaml:"base_url" toml:"base_url"`
+	// Timeout of the HTTP requests, none if zero.
+	Timeout Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+
+	// Encoding is the default encoding of the message bodies, UTF-8 or Big5.
+	Encoding string `json:"encoding" yaml:"encoding" toml:"encoding"`
+	// Response is the default callback URL of the delivery receipts.
+	Response string `json:"response" yaml:"response" toml:"response"`
+	// Validity is the default validity of the messages, from their delivery time.
+	Validity           Duration `json:"validity" yaml:"validity" toml:"validity"`
+	HideDeductedPoints bool     `json:"hide_deducted_points" yaml:"hide_deducted_points" toml:"hide_deducted_points"`
+	ObjectIDPrefix     string   `json:"object_id_prefix" yaml:"object_id_prefix" toml:"object_id_prefix"`
+
+	Retry     *Retry     `json:"retry" yaml:"retry" toml:"retry"`
+	RateLimit *RateLimit `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+	AllowList *AllowList `json:"allow_list" yaml:"allow_list" toml:"allow_list"`
+}
+
+// Retry configures the RetryPolicy of a Client, which only Send uses.
+type Retry struct {
+	MaxAttempts int      `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts"`
+	Backoff     Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackoff  Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+}
+
+// RateLimit configures the Guard of a Client.
+type RateLimit struct {
+	Limit        int      `json:"limit" yaml:"limit" toml:"limit"`
+	Window       Duration `json:"window" yaml:"window" toml:"window"`
+	DedupeWindow Duration `json:"dedupe_window" yaml:"dedupe_window" toml:"dedupe_window"`
+}
+
+// AllowList configures the AllowList of a Client.
+type AllowList struct {
+	Numbers  []string `json:"numbers" yaml:"numbers" toml:"numbers"`
+	Redirect string   `json:"redirect" yaml:"redirect" toml:"redirect"`
+}
+
+// Load reads the configuration file at path, if path is not empty, applies the
+// environment variables on top of it, and validates the result. The format of
+// the file is chosen by its extension: .json, .yaml, .yml or .toml.
+//
+// The environment variables are MITAKE_USERNAME, MITAKE_PASSWORD, MITAKE_BASE_URL,
+// MITAKE_TIMEOUT, MITAKE_ENCODING, MITAKE_RESPONSE, MITAKE_RETRY_MAX_ATTEMPTS,
+// MITAKE_RETRY_BACKOFF, MITAKE_RETRY_MAX_BACKOFF, MITAKE_RATE_LIMIT,
+// MITAKE_RATE_WINDOW, MITAKE_DEDUPE_WINDOW, MITAKE_ALLOW_LIST, a comma separated
+// list of phone numbers, and MITAKE_ALLOW_LIST_REDIRECT.
+func Load(path string) (*Config, error) {
+	cfg := new(Config)
+	if path != "" {
+		if err := cfg.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.LoadEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReadFile reads the configuration file at path over the settings of c.
+func (c *Config) ReadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config %s: unsupported format %q", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadEnv applies the non-empty environment variables returned by getenv over the
+// settings of c, see Load for their names.
+func (c *Config) LoadEnv(getenv func(string) string) error {
+	strs := []struct {
+		name  string
+		value *string
+	}{
+		{"MITAKE_USERNAME", &c.Username},
+		{"MITAKE_PASSWORD", &c.Password},
+		{"MITAKE_BASE_URL", &c.BaseURL},
+		{"MITAKE_ENCODING", &c.Encoding},
+		{"MITAKE_RESPONSE", &c.Response},
+	}
+	for _, s := range strs {
+		if v := getenv(s.name); v != "" {
+			*s.value = v
+		}
+	}
+
+	rateLimit := c.RateLimit
+	if rateLimit == nil {
+		rateLimit = new(RateLimit)
+	}
+	retry := c.Retry
+	if retry == nil {
+		retry = new(Retry)
+	}
+	durations := []struct {
+		name  string
+		value *Duration
+	}{
+		{"MITAKE_TIMEOUT", &c.Timeout},
+		{"MITAKE_RETRY_BACKOFF", &retry.Backoff},
+		{"MITAKE_RETRY_MAX_BACKOFF", &retry.MaxBackoff},
+		{"MITAKE_RATE_WINDOW", &rateLimit.Window},
+		{"MITAKE_DEDUPE_WINDOW", &rateLimit.DedupeWindow},
+	}
+	for _, d := range durations {
+		if v := getenv(d.name); v != "" {
+			if err := d.value.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+		}
+	}
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"MITAKE_RETRY_MAX_ATTEMPTS", &retry.MaxAttempts},
+		{"MITAKE_RATE_LIMIT", &rateLimit.Limit},
+	}
+	for _, i := range ints {
+		if v := getenv(i.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", i.name, err)
+			}
+			*i.value = n
+		}
+	}
+	if *rateLimit != (RateLimit{}) {
+		c.RateLimit = rateLimit
+	}
+	if *retry != (Retry{}) {
+		c.Retry = retry
+	}
+
+	if v := getenv("MITAKE_ALLOW_LIST"); v != "" {
+		if c.AllowList == nil {
+			c.AllowList = new(AllowList)
+		}
+		c.AllowList.Numbers = strings.Split(v, ",")
+		for i, number := range c.AllowList.Numbers {
+			c.AllowList.Numbers[i] = strings.TrimSpace(number)
+		}
+	}
+	if v := getenv("MITAKE_ALLOW_LIST_REDIRECT"); v != "" {
+		if c.AllowList == nil {
+			c.AllowList = new(AllowList)
+		}
+		c.AllowList.Redirect = v
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting of c as a mitake.ParameterError.
+func (c *Config) Validate() error {
+	if c.Username == "" {
+		return &mitake.ParameterError{Reason: "config: empty username"}
+	}
+	if c.Password == "" {
+		return &mitake.ParameterError{Reason: "config: empty password"}
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return &mitake.ParameterError{Reason: fmt.Sprintf("config: invalid base_url %q", c.BaseURL)}
+		}
+	}
+	if c.Encoding != "" {
+		// The same spellings as the charsets of the MO callbacks.
+		switch strings.ToLower(strings.ReplaceAll(c.Encoding, "-", "")) {
+		case "utf8", "big5":
+		default:
+			return &mitake.ParameterError{Reason: fmt.Sprintf("config: invalid encoding %q", c.Encoding)}
+		}
+	}
+	if c.Response != "" {
+		if u, err := url.Parse(c.Response); err != nil || u.Scheme == "" || u.Host == "" {
+			return &mitake.ParameterError{Reason: fmt.Sprintf("config: invalid response %q", c.Response)}
+		}
+	}
+	if c.Timeout < 0 {
+		return &mitake.ParameterError{Reason: "config: negative timeout"}
+	}
+	if c.Validity < 0 {
+		return &mitake.ParameterError{Reason: "config: negative validity"}
+	}
+	if r := c.Retry; r != nil && (r.MaxAttempts < 0 || r.Backoff < 0 || r.MaxBackoff < 0) {
+		return &mitake.ParameterError{Reason: "config: negative retry setting"}
+	}
+	if r := c.RateLimit; r != nil {
+		if r.Limit < 0 || r.Window < 0 || r.DedupeWindow < 0 {
+			return &mitake.ParameterError{Reason: "config: negative rate_limit setting"}
+		}
+		if r.Limit > 0 && r.Window == 0 {
+			return &mitake.ParameterError{Reason: "config: rate_limit requires a window"}
+		}
+	}
+	if a := c.AllowList; a != nil {
+		if len(a.Numbers) == 0 {
+			return &mitake.ParameterError{Reason: "config: empty allow_list numbers"}
+		}
+		for _, number := range a.Numbers {
+			if !validNumber(number) {
+				return &mitake.ParameterError{Reason: fmt.Sprintf("config: invalid allow_list number %q", number)}
+			}
+		}
+		if a.Redirect != "" && !validNumber(a.Redirect) {
+			return &mitake.ParameterError{Reason: fmt.Sprintf("config: invalid allow_list redirect %q", a.Redirect)}
+		}
+	}
+	return nil
+}
+
+// validNumber reports whether number is a phone number, possibly formatted with
+// spaces, dashes and parentheses.
+func validNumber(number string) bool {
+	return strings.ContainsAny(number, "0123456789") &&
+		!strings.ContainsFunc(number, func(r rune) bool { return !strings.ContainsRune("+0123456789 -()", r) })
+}
+
+// NewClient returns a Client wired with the settings of cfg, which is validated
+// first.
+func NewClient(cfg *Config) (*mitake.Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var httpClient *http.Client
+	if cfg.Timeout > 0 {
+		httpClient = &http.Client{Timeout: time.Duration(cfg.Timeout)}
+	}
+	client := mitake.NewClient(cfg.Username, cfg.Password, httpClient)
+
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		client.BaseURL = u
+	}
+	client.Defaults = mitake.MessageDefaults{
+		Encoding:           cfg.Encoding,
+		Response:           cfg.Response,
+		Validity:           time.Duration(cfg.Validity),
+		HideDeductedPoints: cfg.HideDeductedPoints,
+		ObjectIDPrefix:     cfg.ObjectIDPrefix,
+	}
+	if r := cfg.Retry; r != nil && r.MaxAttempts > 1 {
+		client.Retry = &mitake.RetryPolicy{
+			MaxAttempts: r.MaxAttempts,
+			Backoff:     time.Duration(r.Backoff),
+			MaxBackoff:  time.Duration(r.MaxBackoff),
+		}
+	}
+	if r := cfg.RateLimit; r != nil && (r.Limit > 0 || r.DedupeWindow > 0) {
+		client.Guard = &mitake.Guard{
+			RateLimit:    r.Limit,
+			RateWindow:   time.Duration(r.Window),
+			DedupeWindow: time.Duration(r.DedupeWindow),
+		}
+	}
+	if a := cfg.AllowList; a != nil {
+		client.AllowList = &mitake.AllowList{Numbers: a.Numbers, Redirect: a.Redirect}
+	}
+	return client, nil
+}
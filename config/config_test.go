@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/minchao/go-mitake/v2"
+)
+
+func TestConfig_ReadFile(t *testing.T) {
+	want := &Config{
+		Username:  "username",
+		Password:  "password",
+		BaseURL:   "https://example.com/",
+		Timeout:   Duration(10 * time.Second),
+		Encoding:  "Big5",
+		Retry:     &Retry{MaxAttempts: 3, Backoff: Duration(time.Second)},
+		RateLimit: &RateLimit{Limit: 5, Window: Duration(time.Hour)},
+		AllowList: &AllowList{Numbers: []string{"0987654321"}},
+	}
+	files := map[string]string{
+		"mitake.json": `{"username":"username","password":"password","base_url":"https://example.com/","timeout":"10s",
+"encoding":"Big5","retry":{"max_attempts":3,"backoff":"1s"},"rate_limit":{"limit":5,"window":"1h"},
+"allow_list":{"numbers":["0987654321"]}}`,
+		"mitake.yaml": `username: username
+password: password
+base_url: https://example.com/
+timeout: 10s
+encoding: Big5
+retry:
+  max_attempts: 3
+  backoff: 1s
+rate_limit:
+  limit: 5
+  window: 1h
+allow_list:
+  numbers: ["0987654321"]
+`,
+		"mitake.toml": `username = "username"
+password = "password"
+base_url = "https://example.com/"
+timeout = "10s"
+encoding = "Big5"
+
+[retry]
+max_attempts = 3
+backoff = "1s"
+
+[rate_limit]
+limit = 5
+window = "1h"
+
+[allow_list]
+numbers = ["0987654321"]
+`,
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := new(Config)
+		if err := cfg.ReadFile(path); err != nil {
+			t.Fatalf("ReadFile(%s) returned unexpected error: %v", name, err)
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("ReadFile(%s) returned %+v, want %+v", name, cfg, want)
+		}
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	env := map[string]string{
+		"MITAKE_USERNAME":           "env",
+		"MITAKE_TIMEOUT":            "5s",
+		"MITAKE_RATE_LIMIT":         "3",
+		"MITAKE_RATE_WINDOW":        "1m",
+		"MITAKE_ALLOW_LIST":         "0987654321, 0912345678",
+		"MITAKE_RETRY_MAX_ATTEMPTS": "2",
+		"MITAKE_RETRY_BACKOFF":      "2s",
+		"MITAKE_RETRY_MAX_BACKOFF":  "1m",
+	}
+	cfg := &Config{Username: "file", Password: "file"}
+
+	if err := cfg.LoadEnv(func(key string) string { return env[key] }); err != nil {
+		t.Fatalf("LoadEnv returned unexpected error: %v", err)
+	}
+
+	want := &Config{
+		Username:  "env",
+		Password:  "file",
+		Timeout:   Duration(5 * time.Second),
+		Retry:     &Retry{MaxAttempts: 2, Backoff: Duration(2 * time.Second), MaxBackoff: Duration(time.Minute)},
+		RateLimit: &RateLimit{Limit: 3, Window: Duration(time.Minute)},
+		AllowList: &AllowList{Numbers: []string{"0987654321", "0912345678"}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("LoadEnv returned %+v, want %+v", cfg, want)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Password: "password"}, "config: empty username"},
+		{Config{Username: "username", Password: "password", BaseURL: "example.com"}, `config: invalid base_url "example.com"`},
+		{Config{Username: "username", Password: "password", RateLimit: &RateLimit{Limit: 1}}, "config: rate_limit requires a window"},
+		{Config{Username: "username", Password: "password", Encoding: "Shift_JIS"}, `config: invalid encoding "Shift_JIS"`},
+		{Config{Username: "username", Password: "password", AllowList: &AllowList{Numbers: []string{"abc"}}}, `config: invalid allow_list number "abc"`},
+		{Config{Username: "username", Password: "password", AllowList: &AllowList{Numbers: []string{"0987654321", "", "0912345678"}}}, `config: invalid allow_list number ""`},
+		{Config{Username: "username", Password: "password", AllowList: &AllowList{Numbers: []string{"0987654321"}, Redirect: "abc"}}, `config: invalid allow_list redirect "abc"`},
+	}
+
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); !errors.Is(err, &mitake.ParameterError{Reason: tt.want}) {
+			t.Errorf("Validate returned error %v, want %v", err, tt.want)
+		}
+	}
+}
+
+func TestConfig_Validate_allowListCapacity(t *testing.T) {
+	numbers := make([]string, 1, 2)
+	numbers[0] = "0987654321"
+	cfg := Config{Username: "username", Password: "password", AllowList: &AllowList{Numbers: numbers, Redirect: "0912345678"}}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate returned unexpected error: %v", err)
+	}
+	if got := numbers[:2][1]; got != "" {
+		t.Errorf("Validate wrote %q in the spare capacity of the numbers", got)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("CharsetURL"); got != "Big5" {
+			t.Errorf("Send sent CharsetURL %v, want %v", got, "Big5")
+		}
+		if got := r.PostFormValue("response"); got != "https://example.com/callback" {
+			t.Errorf("Send sent response %v, want the default callback URL", got)
+		}
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+
+	client, err := NewClient(&Config{
+		Username:  "username",
+		Password:  "password",
+		BaseURL:   server.URL,
+		Encoding:  "Big5",
+		Response:  "https://example.com/callback",
+		RateLimit: &RateLimit{Limit: 1, Window: Duration(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("NewClient returned unexpected error: %v", err)
+	}
+
+	params := mitake.MessageParams{Message: mitake.Message{Dstaddr: "0987654321", Smbody: "Hello"}}
+	if _, err := client.Send(context.Background(), params); err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	resp, _ := client.Send(context.Background(), params)
+	if resp.Results[0].StatusCode != mitake.StatusThrottled {
+		t.Errorf("Send returned status %v, want %v", resp.Results[0].StatusCode, mitake.StatusThrottled)
+	}
+}
@@ -0,0 +1,130 @@
+package mitake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MessageDefaults holds the settings applied to the messages and requests that do
+// not set them.
+//
+// Response may be a text/template, executed for every message with a
+// ResponseData whose values are escaped with url.QueryEscape, for example:
+//
+//	client.Defaults = mitake.MessageDefaults{
+//		Response:    "https://example.com/receipts?id={{.ClientID}}&token={{.Token}}",
+//		ResponseKey: []byte("secret"),
+//		Validity:    24 * time.Hour,
+//	}
+type MessageDefaults struct {
+	Encoding string // Encoding of the message bodies, defaults to UTF-8
+	Response string // Callback URL of the delivery receipts, may be a template
+	// ResponseKey signs the Token of the ResponseData, see VerifyResponseToken.
+	ResponseKey []byte
+	// Validity sets Vldtime to the delivery time plus Validity, when neither the
+	// message nor the policy of its category set it.
+	Validity           time.Duration
+	HideDeductedPoints bool   // Hide the points deducted per SMS in the responses of the requests that do not set ShowDeductedPoints
+	ObjectIDPrefix     string // Prepended to the ObjectID of the requests that set one
+}
+
+// ResponseData is the data of a Response template. Its values are escaped, so
+// that they can be inserted in the query of the URL as is.
+type ResponseData struct {
+	ClientID string
+	Dstaddr  string
+	Category Category
+	Token    string // HMAC-SHA256 of the ClientID with the ResponseKey, in hex
+}
+
+// VerifyResponseToken reports whether token is the Token of the ResponseData of
+// the message with clientID, signed with key. Use it to authenticate the delivery
+// receipts of a Response template.
+func VerifyResponseToken(key []byte, clientID, token string) bool {
+	want, err := hex.DecodeString(token)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signResponse(key, clientID), want)
+}
+
+func signResponse(key []byte, clientID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(clientID))
+	return mac.Sum(nil)
+}
+
+// encoding returns the encoding of a request, falling back to the defaults.
+func (c *Client) encoding(encoding string) string {
+	switch {
+	case encoding != "":
+		return encoding
+	case c.Defaults.Encoding != "":
+		return c.Defaults.Encoding
+	}
+	return defaultEncoding
+}
+
+// hideDeductedPoints returns whether a request hides the points deducted per
+// SMS: hide wins over show, which wins over the defaults.
+func (c *Client) hideDeductedPoints(hide, show bool) bool {
+	if hide || show {
+		return hide
+	}
+	return c.Defaults.HideDeductedPoints
+}
+
+// objectID returns the ObjectID of a request, with the default prefix.
+func (c *Client) objectID(objectID string) string {
+	if objectID == "" {
+		return ""
+	}
+	return c.Defaults.ObjectIDPrefix + objectID
+}
+
+// response returns the default callback URL of the message.
+func (c *Client) response(message Message) (string, error) {
+	s := c.Defaults.Response
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := c.responseTemplate(s)
+	if err != nil {
+		return "", &ParameterError{Reason: fmt.Sprintf("invalid Response template: %v", err)}
+	}
+	data := ResponseData{
+		ClientID: url.QueryEscape(message.ClientID),
+		Dstaddr:  url.QueryEscape(message.Dstaddr),
+		Category: Category(url.QueryEscape(string(message.Category))),
+	}
+	if c.Defaults.ResponseKey != nil {
+		data.Token = hex.EncodeToString(signResponse(c.Defaults.ResponseKey, message.ClientID))
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", &ParameterError{Reason: fmt.Sprintf("[%s] invalid Response template: %v", message.ClientID, err)}
+	}
+	return b.String(), nil
+}
+
+// responseTemplate returns the parsed Response template, parsing it again only
+// when the defaults changed.
+func (c *Client) responseTemplate(text string) (*template.Template, error) {
+	c.responseMu.Lock()
+	defer c.responseMu.Unlock()
+	if c.responseTmpl != nil && c.responseText == text {
+		return c.responseTmpl, nil
+	}
+	tmpl, err := template.New("response").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	c.responseText, c.responseTmpl = text, tmpl
+	return tmpl, nil
+}
@@ -0,0 +1,138 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Send_defaults(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.Defaults = MessageDefaults{
+		Response:           "https://example.com/callback?id={{.ClientID}}&token={{.Token}}",
+		ResponseKey:        []byte("secret"),
+		Validity:           time.Hour,
+		HideDeductedPoints: true,
+		ObjectIDPrefix:     "app-",
+	}
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		_ = r.ParseForm()
+		if got, want := r.PostForm.Get("objectID"), "app-campaign"; got != want {
+			t.Errorf("Send sent objectID %v, want %v", got, want)
+		}
+		if got := r.PostForm.Get("smsPointFlag"); got != "" {
+			t.Errorf("Send sent smsPointFlag %v, want none", got)
+		}
+		vldtime, err := ParseTime(r.PostForm.Get("vldtime"))
+		if err != nil || vldtime.Sub(time.Now()) > time.Hour || vldtime.Sub(time.Now()) < 59*time.Minute {
+			t.Errorf("Send sent vldtime %v, want an hour from now", r.PostForm.Get("vldtime"))
+		}
+		token := strings.TrimPrefix(r.PostForm.Get("response"), "https://example.com/callback?id=id1&token=")
+		if !VerifyResponseToken([]byte("secret"), "id1", token) {
+			t.Errorf("Send sent response %v, want a signed callback URL", r.PostForm.Get("response"))
+		}
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+
+	_, err := client.Send(context.Background(), MessageParams{
+		ObjectID: "campaign",
+		Message:  Message{ClientID: "id1", Dstaddr: "0987654321", Smbody: "Hello"},
+	})
+	if err != nil {
+		t.Errorf("Send returned unexpected error: %v", err)
+	}
+}
+
+func TestClient_SendBatch_defaults(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.Defaults = MessageDefaults{
+		Encoding:           "Big5",
+		Response:           "https://example.com/callback/{{.ClientID}}",
+		HideDeductedPoints: true,
+		ObjectIDPrefix:     "app-",
+	}
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		testRequestURI(t, r, "/b2c/mtk/SmBulkSend?Encoding_PostIn=Big5&objectID=app-campaign&password=password&username=username")
+		body, _ := io.ReadAll(r.Body)
+		want := "id1$$0987654321$$$$$$$$https://example.com/callback/id1$$Hello\r\n" +
+			"id2$$0912345678$$$$$$$$https://example.com/override$$Hi\r\n"
+		if got := string(body); got != want {
+			t.Errorf("SendBatch sent %q, want %q", got, want)
+		}
+		_, _ = fmt.Fprint(w, "[id1]\nmsgid=#000000013\nstatuscode=1\n[id2]\nmsgid=#000000014\nstatuscode=1\nAccountPoint=126\n")
+	})
+
+	_, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		ObjectID: "campaign",
+		Messages: []Message{
+			{ClientID: "id1", Dstaddr: "0987654321", Smbody: "Hello"},
+			{ClientID: "id2", Dstaddr: "0912345678", Smbody: "Hi", Response: "https://example.com/override"},
+		},
+	})
+	if err != nil {
+		t.Errorf("SendBatch returned unexpected error: %v", err)
+	}
+}
+
+func TestClient_Send_defaultsOverride(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	client.Defaults = MessageDefaults{
+		Response:           "https://example.com/callback?to={{.Dstaddr}}",
+		HideDeductedPoints: true,
+	}
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if got, want := r.PostForm.Get("smsPointFlag"), "1"; got != want {
+			t.Errorf("Send sent smsPointFlag %v, want %v", got, want)
+		}
+		if got, want := r.PostForm.Get("response"), "https://example.com/callback?to=%2B886987654321"; got != want {
+			t.Errorf("Send sent response %v, want %v", got, want)
+		}
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+
+	params := MessageParams{ShowDeductedPoints: true, Message: Message{Dstaddr: "+886987654321", Smbody: "Hello"}}
+	for range 2 {
+		if _, err := client.Send(context.Background(), params); err != nil {
+			t.Errorf("Send returned unexpected error: %v", err)
+		}
+	}
+	tmpl := client.responseTmpl
+	if _, err := client.Send(context.Background(), params); err != nil || client.responseTmpl != tmpl {
+		t.Errorf("Send parsed the Response template again")
+	}
+}
+
+func TestClient_Send_invalidResponseTemplate(t *testing.T) {
+	client := NewClient("username", "password", nil)
+	client.Defaults.Response = "https://example.com/{{.Unknown}}"
+
+	_, err := client.Send(context.Background(), MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}})
+	if _, ok := err.(*ParameterError); !ok {
+		t.Errorf("Send returned error %v, want a ParameterError", err)
+	}
+}
+
+func TestVerifyResponseToken(t *testing.T) {
+	token := "2fc5b7ba5e4a25a1bb3d3f0d4d7e0ed6c2a0b5f0c6a3c8c1e2b4c0b9f8f7a6e5"
+	if VerifyResponseToken([]byte("secret"), "id1", token) {
+		t.Errorf("VerifyResponseToken accepted a wrong token")
+	}
+	if VerifyResponseToken([]byte("secret"), "id1", "not hex") {
+		t.Errorf("VerifyResponseToken accepted a malformed token")
+	}
+}
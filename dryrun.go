@@ -0,0 +1,221 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRun records the requests of a client instead of sending them to Mitake, and
+// answers them with synthetic responses, so that the validation and encoding of
+// every call can be exercised without sending real SMS.
+//
+// Example usage:
+//
+//	dryRun := mitake.NewDryRun()
+//	client.DryRun = dryRun
+//	resp, err := client.Send(ctx, params) // resp has a fake msgid
+//	requests := dryRun.Requests()
+type DryRun struct {
+	// AccountPoint is the balance reported by the synthetic responses.
+	AccountPoint int
+	// ReceiptClient, when set, is used to deliver a synthetic delivery receipt to the
+	// Response URL of every message that has one.
+	ReceiptClient *http.Client
+	// ReceiptStatus is the status code of the synthetic receipts, defaults to StatusDelivered.
+	ReceiptStatus StatusCode
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+	seq      int
+	receipts sync.WaitGroup
+}
+
+// RecordedRequest is a request that a client in dry-run mode would have made.
+type RecordedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+}
+
+// NewDryRun returns a new DryRun.
+func NewDryRun() *DryRun {
+	return &DryRun{AccountPoint: 100}
+}
+
+// Requests returns the recorded requests in the order they were made.
+func (d *DryRun) Requests() []RecordedRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]RecordedRequest(nil), d.requests...)
+}
+
+// Reset discards the recorded requests.
+func (d *DryRun) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = nil
+}
+
+// Wait blocks until every synthetic receipt has been delivered.
+func (d *DryRun) Wait() {
+	d.receipts.Wait()
+}
+
+// nextMsgid returns a fake msgid, which never collides with the msgids of Mitake.
+func (d *DryRun) nextMsgid() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seq++
+	return fmt.Sprintf("#DRYRUN%08d", d.seq)
+}
+
+// do records the request and returns a synthetic response.
+func (d *DryRun) do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   string(body),
+	})
+	d.mu.Unlock()
+
+	var b strings.Builder
+	switch path.Base(req.URL.Path) {
+	case "SmSend":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		message := Message{
+			Dstaddr:  form.Get("dstaddr"),
+			Smbody:   form.Get("smbody"),
+			Dlvtime:  form.Get("dlvtime"),
+			Response: form.Get("response"),
+		}
+		d.writeResult(&b, "1", message, form.Get("smsPointFlag") != "")
+		_, _ = fmt.Fprintf(&b, "AccountPoint=%d\n", d.AccountPoint)
+	case "SmBulkSend":
+		flag := req.URL.Query().Get("smsPointFlag") != ""
+		for _, line := range strings.Split(string(body), "\r\n") {
+			fields := strings.SplitN(line, batchFieldSeparator, 7)
+			if len(fields) != 7 {
+				continue
+			}
+			message := Message{Dstaddr: fields[1], Dlvtime: fields[2], Response: fields[5], Smbody: fields[6]}
+			d.writeResult(&b, fields[0], message, flag)
+		}
+		_, _ = fmt.Fprintf(&b, "AccountPoint=%d\n", d.AccountPoint)
+	case "SmQuery":
+		msgids := req.URL.Query().Get("msgid")
+		if msgids == "" {
+			_, _ = fmt.Fprintf(&b, "AccountPoint=%d", d.AccountPoint)
+			break
+		}
+		now := FormatTime(time.Now())
+		for _, msgid := range strings.Split(msgids, ",") {
+			_, _ = fmt.Fprintf(&b, "%s\t%s\t%s\n", msgid, string(d.receiptStatus()), now)
+		}
+	case "SmCancel":
+		for _, msgid := range strings.Split(req.URL.Query().Get("msgid"), ",") {
+			_, _ = fmt.Fprintf(&b, "%s=%s\n", msgid, string(StatusReservationCanceled))
+		}
+	default:
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader(b.String())),
+		ContentLength: int64(b.Len()),
+		Request:       req,
+	}, nil
+}
+
+func (d *DryRun) receiptStatus() StatusCode {
+	if d.ReceiptStatus != "" {
+		return d.ReceiptStatus
+	}
+	return StatusDelivered
+}
+
+// writeResult writes a synthetic result of the message, and schedules its receipt.
+func (d *DryRun) writeResult(w io.Writer, key string, message Message, smsPointFlag bool) {
+	msgid := d.nextMsgid()
+	status := StatusCarrierAccepted
+	if message.Dlvtime != "" {
+		status = StatusReservationForDelivery
+	}
+	_, _ = fmt.Fprintf(w, "[%s]\nmsgid=%s\nstatuscode=%s\n", key, msgid, string(status))
+	if smsPointFlag {
+		_, _ = fmt.Fprintf(w, "smsPoint=%d\n", CountSegments(message.Smbody).Count)
+	}
+
+	if d.ReceiptClient != nil && message.Response != "" {
+		d.receipts.Add(1)
+		go func() {
+			defer d.receipts.Done()
+			d.sendReceipt(msgid, message)
+		}()
+	}
+}
+
+// sendReceipt delivers a synthetic receipt to the Response URL of the message, the
+// same way Mitake does.
+func (d *DryRun) sendReceipt(msgid string, message Message) {
+	u, err := url.Parse(message.Response)
+	if err != nil {
+		return
+	}
+	now := FormatTime(time.Now())
+	status := d.receiptStatus()
+	q := u.Query()
+	q.Set("msgid", msgid)
+	q.Set("dstaddr", message.Dstaddr)
+	q.Set("dlvtime", now)
+	q.Set("donetime", now)
+	q.Set("statuscode", string(status))
+	q.Set("statusstr", status.String())
+	q.Set("StatusFlag", string(status))
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return
+	}
+	resp, err := d.ReceiptClient.Do(req)
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+}
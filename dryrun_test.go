@@ -0,0 +1,97 @@
+package mitake
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClient_DryRun_Send(t *testing.T) {
+	client := NewClient("username", "password", nil)
+	dryRun := NewDryRun()
+	client.DryRun = dryRun
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{
+			Dstaddr: "0987654321",
+			Smbody:  "Hello, 世界",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if got := resp.Results[0].Msgid; !strings.HasPrefix(got, "#DRYRUN") {
+		t.Errorf("Send returned msgid %v, want a fake msgid", got)
+	}
+	if got, want := resp.Results[0].StatusCode, StatusCarrierAccepted; got != want {
+		t.Errorf("Send returned status %v, want %v", got, want)
+	}
+
+	requests := dryRun.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("DryRun recorded %d requests, want %d", len(requests), 1)
+	}
+	if got, want := requests[0].URL, defaultBaseURL+"b2c/mtk/SmSend?CharsetURL=UTF-8"; got != want {
+		t.Errorf("DryRun recorded URL %v, want %v", got, want)
+	}
+	if !strings.Contains(requests[0].Body, "dstaddr=0987654321") {
+		t.Errorf("DryRun recorded body %v, want the form data", requests[0].Body)
+	}
+
+	point, err := client.QueryAccountPoint(context.Background())
+	if err != nil {
+		t.Fatalf("QueryAccountPoint returned unexpected error: %v", err)
+	}
+	if point != dryRun.AccountPoint {
+		t.Errorf("QueryAccountPoint returned %v, want %v", point, dryRun.AccountPoint)
+	}
+}
+
+func TestClient_DryRun_SendBatch_receipts(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		receipts []*MessageReceipt
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := ParseMessageReceipt(r)
+		if err != nil {
+			t.Errorf("ParseMessageReceipt returned unexpected error: %v", err)
+			return
+		}
+		mu.Lock()
+		receipts = append(receipts, receipt)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := NewClient("username", "password", nil)
+	client.DryRun = NewDryRun()
+	client.DryRun.ReceiptClient = server.Client()
+
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1", Response: server.URL + "/callback"},
+			{ClientID: "1aab", Dstaddr: "0987654322", Smbody: "Test2", Dlvtime: "20370101010000"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+	client.DryRun.Wait()
+
+	if got, want := resp.Results[1].StatusCode, StatusReservationForDelivery; got != want {
+		t.Errorf("SendBatch returned status %v, want %v", got, want)
+	}
+	if len(receipts) != 1 {
+		t.Fatalf("DryRun delivered %d receipts, want %d", len(receipts), 1)
+	}
+	if got, want := receipts[0].Msgid, resp.Results[0].Msgid; got != want {
+		t.Errorf("DryRun delivered receipt for %v, want %v", got, want)
+	}
+	if got, want := receipts[0].Statusstring, StatusDelivered; got != want {
+		t.Errorf("DryRun delivered receipt with status %v, want %v", got, want)
+	}
+}
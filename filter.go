@@ -0,0 +1,168 @@
+package mitake
+
+import (
+	"context"
+	"time"
+)
+
+// preparedMessage is a message after the client-side policies have been applied.
+type preparedMessage struct {
+	Message
+	// result is the synthetic result of a message that must not be sent.
+	result *MessageResult
+	// annotations record on the result of the message what the policies did.
+	annotations []func(*MessageResult)
+	// rollbacks undo what the policies recorded, if Mitake does not accept the message.
+	rollbacks []func(context.Context)
+	// sent is the result of Mitake, set by mergeResults.
+	sent *MessageResult
+}
+
+// onFailure registers fn to be called if Mitake does not accept the message.
+func (p *preparedMessage) onFailure(fn func(context.Context)) {
+	p.rollbacks = append(p.rollbacks, fn)
+}
+
+// settle calls the rollbacks of the message unless result shows that Mitake
+// accepted it. A nil result means the message was not sent.
+func (p *preparedMessage) settle(ctx context.Context, result *MessageResult) {
+	if result != nil && result.StatusCode.Class() == StatusClassSuccess {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, rollback := range p.rollbacks {
+		rollback(ctx)
+	}
+	p.rollbacks = nil
+}
+
+// settleMessages settles the prepared messages that were not dropped with the
+// results set by mergeResults, or as not sent if err is not nil.
+func settleMessages(ctx context.Context, prepared []*preparedMessage, err error) {
+	for _, p := range prepared {
+		if p.result != nil {
+			continue
+		}
+		if err != nil {
+			p.settle(ctx, nil)
+		} else {
+			p.settle(ctx, p.sent)
+		}
+	}
+}
+
+// apply fills in result with the annotations of the message.
+func (p *preparedMessage) apply(result *MessageResult) {
+	for _, annotate := range p.annotations {
+		annotate(result)
+	}
+}
+
+// drop marks the message as not to be sent, with a synthetic status.
+func (p *preparedMessage) drop(status StatusCode) {
+	p.result = &MessageResult{ClientID: p.ClientID, StatusCode: status}
+	p.apply(p.result)
+}
+
+// prepareMessage applies the client-side policies to the message.
+func (c *Client) prepareMessage(ctx context.Context, message Message) (*preparedMessage, error) {
+	p := &preparedMessage{Message: message}
+	policy := c.policy(message)
+	if c.AllowList != nil {
+		c.AllowList.apply(p)
+	}
+	if c.Suppressions != nil && p.result == nil && !policy.SkipSuppressions {
+		s, err := c.Suppressions.Get(ctx, p.Dstaddr)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			p.drop(StatusOptedOut)
+		}
+	}
+	if policy.Guard != nil && p.result == nil {
+		if err := policy.Guard.apply(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	if p.result != nil {
+		return p, nil
+	}
+	// The guard has recorded the message by now, so it must be settled as not
+	// sent if a later step fails.
+	if policy.QuietHours != nil {
+		if err := policy.QuietHours.apply(p); err != nil {
+			p.settle(ctx, nil)
+			return nil, err
+		}
+	}
+	validity := policy.Validity
+	if validity == 0 {
+		validity = c.Defaults.Validity
+	}
+	if err := applyValidity(p, validity, time.Now()); err != nil {
+		p.settle(ctx, nil)
+		return nil, err
+	}
+	if p.Response == "" {
+		response, err := c.response(p.Message)
+		if err != nil {
+			p.settle(ctx, nil)
+			return nil, err
+		}
+		p.Response = response
+	}
+	return p, nil
+}
+
+// prepareMessages applies the client-side policies to the messages. If one of them
+// fails, the messages prepared before it are settled as not sent.
+func (c *Client) prepareMessages(ctx context.Context, messages []Message) ([]*preparedMessage, error) {
+	prepared := make([]*preparedMessage, 0, len(messages))
+	for _, message := range messages {
+		p, err := c.prepareMessage(ctx, message)
+		if err != nil {
+			settleMessages(ctx, prepared, err)
+			return nil, err
+		}
+		prepared = append(prepared, p)
+	}
+	return prepared, nil
+}
+
+// mergeResults returns the results of the prepared messages in order, taking the
+// synthetic results of the dropped messages, and the results of Mitake by ClientID
+// for the others.
+func mergeResults(prepared []*preparedMessage, response *MessageResponse) []*MessageResult {
+	sent := make(map[string]*MessageResult)
+	var unkeyed []*MessageResult
+	if response != nil {
+		for _, result := range response.Results {
+			if _, ok := sent[result.ClientID]; ok || result.ClientID == "" {
+				unkeyed = append(unkeyed, result)
+				continue
+			}
+			sent[result.ClientID] = result
+		}
+	}
+
+	results := make([]*MessageResult, 0, len(prepared))
+	for _, p := range prepared {
+		if p.result != nil {
+			results = append(results, p.result)
+			continue
+		}
+		result, ok := sent[p.ClientID]
+		if ok {
+			delete(sent, p.ClientID)
+		} else if len(unkeyed) > 0 {
+			result, unkeyed = unkeyed[0], unkeyed[1:]
+		} else {
+			continue
+		}
+		p.apply(result)
+		p.sent = result
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,165 @@
+package mitake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// GuardStore holds the state of a Guard. It must be safe for concurrent use, and
+// can be shared by the processes that send to the same recipients.
+type GuardStore interface {
+	// Add records an event under key at the given time, and returns the number of
+	// events recorded under key after since, including this one.
+	Add(ctx context.Context, key string, at, since time.Time) (int, error)
+	// Remove deletes an event recorded under key at the given time.
+	Remove(ctx context.Context, key string, at time.Time) error
+}
+
+// Guard protects recipients from bugs that send the same SMS over and over. It
+// drops a message with the StatusDuplicateSuppressed status when the same Smbody
+// was sent to the same Dstaddr within DedupeWindow, and with the StatusThrottled
+// status when more than RateLimit messages were sent to the Dstaddr within
+// RateWindow. Dropped messages count towards the rate limit, so a recipient stays
+// throttled while the bug lasts, but the events of a message that Mitake did not
+// accept are removed, so that it can be sent again at once.
+//
+// Example usage:
+//
+//	client.Guard = &mitake.Guard{
+//		RateLimit:    5,
+//		RateWindow:   time.Hour,
+//		DedupeWindow: 10 * time.Minute,
+//	}
+type Guard struct {
+	// Store holds the state of the guard, defaults to a MemoryGuardStore.
+	Store GuardStore
+
+	RateLimit    int           // Messages allowed per recipient within RateWindow, zero disables the limit
+	RateWindow   time.Duration // Window of the rate limit
+	DedupeWindow time.Duration // Window in which identical messages are suppressed, zero disables it
+
+	once sync.Once
+	now  func() time.Time // For testing
+}
+
+// apply drops the message if it is throttled or a duplicate.
+func (g *Guard) apply(ctx context.Context, p *preparedMessage) error {
+	g.once.Do(func() {
+		if g.Store == nil {
+			g.Store = NewMemoryGuardStore()
+		}
+	})
+	now := time.Now()
+	if g.now != nil {
+		now = g.now()
+	}
+	dstaddr := normalizePhoneNumber(p.Dstaddr)
+
+	var keys []string
+	if g.DedupeWindow > 0 {
+		sum := sha256.Sum256([]byte(p.Smbody))
+		key := "dedupe:" + dstaddr + ":" + hex.EncodeToString(sum[:])
+		n, err := g.Store.Add(ctx, key, now, now.Add(-g.DedupeWindow))
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			p.drop(StatusDuplicateSuppressed)
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	if g.RateLimit > 0 {
+		key := "rate:" + dstaddr
+		n, err := g.Store.Add(ctx, key, now, now.Add(-g.RateWindow))
+		if err != nil {
+			return err
+		}
+		if n > g.RateLimit {
+			// The message is not sent, so its body is not a duplicate of a later one.
+			g.remove(ctx, keys, now)
+			p.drop(StatusThrottled)
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		p.onFailure(func(ctx context.Context) { g.remove(ctx, keys, now) })
+	}
+	return nil
+}
+
+// remove deletes the events recorded under keys at the given time.
+func (g *Guard) remove(ctx context.Context, keys []string, at time.Time) {
+	for _, key := range keys {
+		_ = g.Store.Remove(ctx, key, at)
+	}
+}
+
+// MemoryGuardStore is a GuardStore in memory.
+type MemoryGuardStore struct {
+	mu     sync.Mutex
+	events map[string][]time.Time
+	window time.Duration // Longest window seen, to expire the idle keys
+	swept  time.Time
+}
+
+// NewMemoryGuardStore returns a new MemoryGuardStore.
+func NewMemoryGuardStore() *MemoryGuardStore {
+	return &MemoryGuardStore{events: make(map[string][]time.Time)}
+}
+
+// Add records an event under key, and returns the number of events after since.
+func (s *MemoryGuardStore) Add(_ context.Context, key string, at, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.events[key]
+	i := 0
+	for i < len(events) && !events[i].After(since) {
+		i++
+	}
+	events = append(events[i:], at)
+	s.events[key] = events
+
+	if window := at.Sub(since); window > s.window {
+		s.window = window
+	}
+	if at.Sub(s.swept) >= s.window {
+		s.sweep(at)
+	}
+	return len(events), nil
+}
+
+// Remove deletes the latest event recorded under key at the given time.
+func (s *MemoryGuardStore) Remove(_ context.Context, key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.events[key]
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Equal(at) {
+			events = append(events[:i], events[i+1:]...)
+			break
+		}
+	}
+	if len(events) == 0 {
+		delete(s.events, key)
+	} else {
+		s.events[key] = events
+	}
+	return nil
+}
+
+// sweep deletes the keys whose last event is older than the longest window.
+func (s *MemoryGuardStore) sweep(now time.Time) {
+	for key, events := range s.events {
+		if now.Sub(events[len(events)-1]) > s.window {
+			delete(s.events, key)
+		}
+	}
+	s.swept = now
+}
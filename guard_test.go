@@ -0,0 +1,180 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClient_SendBatch_guard(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.Guard = &Guard{RateLimit: 2, RateWindow: time.Hour, DedupeWindow: time.Minute}
+
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Your code is 1234"},
+			{ClientID: "1aab", Dstaddr: "+886987654321", Smbody: "Your code is 1234"},
+			{ClientID: "2aab", Dstaddr: "0987654321", Smbody: "Your code is 5678"},
+			{ClientID: "3aab", Dstaddr: "0987654321", Smbody: "Your code is 9012"},
+			{ClientID: "4aab", Dstaddr: "0912345678", Smbody: "Your code is 1234"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	var statuses []StatusCode
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.StatusCode)
+	}
+	want := []StatusCode{StatusCarrierAccepted, StatusDuplicateSuppressed, StatusCarrierAccepted, StatusThrottled, StatusCarrierAccepted}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("SendBatch returned statuses %v, want %v", statuses, want)
+	}
+}
+
+func TestClient_Send_guardWindow(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.Guard = &Guard{DedupeWindow: time.Minute, now: func() time.Time { return now }}
+
+	params := MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}}
+	for _, tt := range []struct {
+		after time.Duration
+		want  StatusCode
+	}{
+		{0, StatusCarrierAccepted},
+		{30 * time.Second, StatusDuplicateSuppressed},
+		{time.Minute, StatusCarrierAccepted},
+	} {
+		now = now.Add(tt.after)
+		resp, err := client.Send(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Send returned unexpected error: %v", err)
+		}
+		if got := resp.Results[0].StatusCode; got != tt.want {
+			t.Errorf("Send after %v returned status %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryGuardStore(t *testing.T) {
+	s := NewMemoryGuardStore()
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, want := range []int{1, 2, 2} {
+		at := now.Add(time.Duration(i) * time.Minute)
+		n, err := s.Add(context.Background(), "key", at, at.Add(-90*time.Second))
+		if err != nil {
+			t.Fatalf("Add returned unexpected error: %v", err)
+		}
+		if n != want {
+			t.Errorf("Add %d returned %d, want %d", i, n, want)
+		}
+	}
+
+	_, _ = s.Add(context.Background(), "other", now.Add(time.Hour), now.Add(time.Hour-time.Minute))
+	if _, ok := s.events["key"]; ok {
+		t.Error("MemoryGuardStore did not expire an idle key")
+	}
+}
+
+func TestClient_Send_guardRetry(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	statuses := []string{"a", "1"}
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "[1]\nmsgid=#000000013\nstatuscode=%s\nAccountPoint=126\n", statuses[0])
+		statuses = statuses[1:]
+	})
+	client.Guard = &Guard{RateLimit: 1, RateWindow: time.Hour, DedupeWindow: time.Minute}
+
+	params := MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Your code is 1234"}}
+	for _, want := range []StatusCode{"a", StatusCarrierAccepted, StatusDuplicateSuppressed} {
+		resp, err := client.Send(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Send returned unexpected error: %v", err)
+		}
+		if got := resp.Results[0].StatusCode; got != want {
+			t.Errorf("Send returned status %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMemoryGuardStore_Remove(t *testing.T) {
+	s := NewMemoryGuardStore()
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, _ = s.Add(context.Background(), "key", now, now.Add(-time.Minute))
+	if err := s.Remove(context.Background(), "key", now); err != nil {
+		t.Fatalf("Remove returned unexpected error: %v", err)
+	}
+	if n, _ := s.Add(context.Background(), "key", now, now.Add(-time.Minute)); n != 1 {
+		t.Errorf("Add after Remove returned %d, want 1", n)
+	}
+}
+
+// failingSuppressionStore fails to look up dstaddr.
+type failingSuppressionStore struct {
+	MemorySuppressionStore
+	dstaddr string
+}
+
+func (s *failingSuppressionStore) Get(ctx context.Context, dstaddr string) (*Suppression, error) {
+	if dstaddr == s.dstaddr {
+		return nil, errors.New("store unavailable")
+	}
+	return s.MemorySuppressionStore.Get(ctx, dstaddr)
+}
+
+func TestClient_SendBatch_guardPrepareError(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.Guard = &Guard{RateLimit: 1, RateWindow: time.Hour, DedupeWindow: time.Minute}
+	store := &failingSuppressionStore{dstaddr: "0912345678"}
+	client.Suppressions = store
+
+	opts := BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Your code is 1234"},
+			{ClientID: "1aab", Dstaddr: "0912345678", Smbody: "Your code is 1234"},
+		},
+	}
+	if _, err := client.SendBatch(context.Background(), opts); err == nil {
+		t.Fatal("SendBatch expected an error")
+	}
+
+	store.dstaddr = ""
+	client.Defaults.Response = "https://example.com/{{.Missing}}"
+	if _, err := client.SendBatch(context.Background(), opts); err == nil {
+		t.Fatal("SendBatch expected an error")
+	}
+
+	client.Defaults.Response = ""
+	resp, err := client.SendBatch(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+	for _, result := range resp.Results {
+		if result.StatusCode != StatusCarrierAccepted {
+			t.Errorf("SendBatch returned status %v for %s, want %v", result.StatusCode, result.ClientID, StatusCarrierAccepted)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// InboundMessage represents a reply sent by a user to one of our numbers, a mobile
+// originated (MO) message.
+type InboundMessage struct {
+	Msgid         string    // The msgid of the reply
+	Sender        string    // Phone number of the user who replied
+	Recipient     string    // Our number the reply was sent to
+	Body          string    // The text of the reply, decoded to UTF-8
+	ReceivedAt    time.Time // When Mitake received the reply, zero if not available
+	OriginalMsgid string    // The msgid of the message replied to, when available
+	OptOut        bool      // Whether the body is an opt-out keyword, see IsOptOut
+}
+
+// InboundParameters lists the names of the parameters of a MO callback by field of
+// the InboundMessage, in order of preference. Names are matched without regard to
+// case.
+//
+// Mitake sets up the MO callback per account, and the parameter names it sends
+// depend on the service contracted. Compare them with the MO specification
+// provided by Mitake for the account, and use custom InboundParameters when they
+// differ from DefaultInboundParameters.
+type InboundParameters struct {
+	Msgid         []string
+	Sender        []string
+	Recipient     []string
+	Body          []string
+	ReceivedAt    []string // In the YYYYMMDDHHMMSS layout, in Taipei time
+	OriginalMsgid []string
+	Charset       []string // Charset of the body, defaults to the one of the Content-Type
+}
+
+// DefaultInboundParameters are the parameter names of ParseInboundMessage. They
+// follow the names of the SmSend API, such as dstaddr and smbody, and accept common
+// variants of them.
+var DefaultInboundParameters = InboundParameters{
+	Msgid:         []string{"msgid"},
+	Sender:        []string{"srcaddr", "sender"},
+	Recipient:     []string{"dstaddr", "destaddr"},
+	Body:          []string{"smbody", "msg", "text"},
+	ReceivedAt:    []string{"rcvtime", "recvtime", "donetime"},
+	OriginalMsgid: []string{"orgmsgid", "originalmsgid"},
+	Charset:       []string{"charset"},
+}
+
+// optOutKeywords are the bodies recognized as opt-out requests.
+var optOutKeywords = []string{"STOP", "UNSUBSCRIBE", "退訂", "取消訂閱", "拒收"}
+
+// IsOptOut reports whether the body of a reply is an opt-out keyword, such as STOP
+// or 退訂, ignoring case, spaces and punctuation around it.
+func IsOptOut(body string) bool {
+	body = strings.TrimFunc(body, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	for _, keyword := range optOutKeywords {
+		if strings.EqualFold(body, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseInboundMessage parses an incoming Mitake MO callback request, sent with GET
+// or POST, with the DefaultInboundParameters, see InboundParameters.Parse.
+//
+// Example usage:
+//
+//	func Reply(w http.ResponseWriter, r *http.Request) {
+//		message, err := mitake.ParseInboundMessage(r)
+//		if err != nil { ... }
+//		// Process the reply
+//	}
+func ParseInboundMessage(r *http.Request) (*InboundMessage, error) {
+	return DefaultInboundParameters.Parse(r)
+}
+
+// Parse parses an incoming MO callback request, sent with GET or POST, and returns
+// the InboundMessage. The body is decoded from the charset of the charset parameter
+// or of the Content-Type, and from Big5, the default of Mitake, when it is not
+// valid UTF-8.
+func (p *InboundParameters) Parse(r *http.Request) (*InboundMessage, error) {
+	values, err := inboundValues(r)
+	if err != nil {
+		return nil, err
+	}
+	get := func(names []string) string {
+		for _, name := range names {
+			if v := values.Get(strings.ToLower(name)); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	m := &InboundMessage{
+		Msgid:         get(p.Msgid),
+		Sender:        get(p.Sender),
+		Recipient:     get(p.Recipient),
+		OriginalMsgid: get(p.OriginalMsgid),
+	}
+	if m.Sender == "" {
+		return nil, errors.New("inbound message not found")
+	}
+
+	charset := get(p.Charset)
+	if charset == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+			charset = params["charset"]
+		}
+	}
+	if m.Body, err = decodeBody(get(p.Body), charset); err != nil {
+		return nil, err
+	}
+	m.OptOut = IsOptOut(m.Body)
+
+	if receivedAt := get(p.ReceivedAt); receivedAt != "" {
+		if m.ReceivedAt, err = ParseTime(receivedAt); err != nil {
+			return nil, fmt.Errorf("invalid received time %q", receivedAt)
+		}
+	}
+	return m, nil
+}
+
+// inboundValues returns the parameters of the request with lower case names.
+func inboundValues(r *http.Request) (url.Values, error) {
+	values := r.URL.Query()
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		values = r.Form
+	}
+	lower := make(url.Values, len(values))
+	for name, v := range values {
+		name = strings.ToLower(name)
+		lower[name] = append(lower[name], v...)
+	}
+	return lower, nil
+}
+
+// decodeBody decodes the raw body from charset to UTF-8.
+func decodeBody(body, charset string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(charset, "-", "")) {
+	case "utf8":
+		return body, nil
+	case "big5":
+	default:
+		if utf8.ValidString(body) {
+			return body, nil
+		}
+	}
+	decoded, err := traditionalchinese.Big5.NewDecoder().String(body)
+	if err != nil {
+		return "", fmt.Errorf("decode Big5 body: %w", err)
+	}
+	return decoded, nil
+}
+
+// InboundHandler is an http.Handler receiving the MO callbacks of Mitake.
+//
+// Example usage:
+//
+//	http.Handle("/mitake/reply", &mitake.InboundHandler{
+//		Suppressions: client.Suppressions,
+//		OnMessage: func(ctx context.Context, m *mitake.InboundMessage) error { ... },
+//	})
+type InboundHandler struct {
+	// Parameters, when set, replaces the DefaultInboundParameters.
+	Parameters *InboundParameters
+	// Suppressions, when set, suppresses the senders of opt-out replies.
+	Suppressions SuppressionStore
+	// OnMessage, when set, is called with every reply. An error makes the handler
+	// answer 500, so that Mitake sends the callback again.
+	OnMessage func(ctx context.Context, m *InboundMessage) error
+}
+
+func (h *InboundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parameters := h.Parameters
+	if parameters == nil {
+		parameters = &DefaultInboundParameters
+	}
+	m, err := parameters.Parse(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.OptOut && h.Suppressions != nil {
+		createdAt := m.ReceivedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		err := h.Suppressions.Add(r.Context(), Suppression{Dstaddr: m.Sender, Reason: m.Body, CreatedAt: createdAt})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if h.OnMessage != nil {
+		if err := h.OnMessage(r.Context(), m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	_, _ = fmt.Fprintf(w, "magicid=sms_gateway_rpack\nmsgid=%s\n", m.Msgid)
+}
@@ -0,0 +1,122 @@
+package mitake
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func TestParseInboundMessage(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/reply?msgid=M0001&SrcAddr=0987654321&DstAddr=0911111111&smbody=%E4%BD%A0%E5%A5%BD&RcvTime=20170101120000&OrgMsgid=1010079522", nil)
+
+	m, err := ParseInboundMessage(r)
+	if err != nil {
+		t.Fatalf("ParseInboundMessage returned unexpected error: %v", err)
+	}
+
+	want := &InboundMessage{
+		Msgid:         "M0001",
+		Sender:        "0987654321",
+		Recipient:     "0911111111",
+		Body:          "你好",
+		ReceivedAt:    time.Date(2017, 1, 1, 12, 0, 0, 0, Taipei),
+		OriginalMsgid: "1010079522",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("ParseInboundMessage returned %+v, want %+v", m, want)
+	}
+}
+
+func TestParseInboundMessage_big5(t *testing.T) {
+	body, _ := traditionalchinese.Big5.NewEncoder().String("退訂")
+	form := url.Values{"msgid": {"M0001"}, "srcaddr": {"0987654321"}, "smbody": {body}}
+	r, _ := http.NewRequest("POST", "/reply", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	m, err := ParseInboundMessage(r)
+	if err != nil {
+		t.Fatalf("ParseInboundMessage returned unexpected error: %v", err)
+	}
+	if m.Body != "退訂" || !m.OptOut {
+		t.Errorf("ParseInboundMessage returned body %q, OptOut %v", m.Body, m.OptOut)
+	}
+}
+
+func TestInboundParameters_Parse(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/reply?MsgID=M0001&From=0987654321&Content=STOP", nil)
+	p := &InboundParameters{Msgid: []string{"MsgID"}, Sender: []string{"From"}, Body: []string{"Content"}}
+
+	m, err := p.Parse(r)
+	if err != nil {
+		t.Fatalf("Parse returned unexpected error: %v", err)
+	}
+	want := &InboundMessage{Msgid: "M0001", Sender: "0987654321", Body: "STOP", OptOut: true}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Parse returned %+v, want %+v", m, want)
+	}
+	if _, err := ParseInboundMessage(r); err == nil {
+		t.Error("ParseInboundMessage did not return error")
+	}
+}
+
+func TestParseInboundMessage_notFound(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/reply", nil)
+
+	if _, err := ParseInboundMessage(r); err == nil {
+		t.Error("ParseInboundMessage did not return error")
+	}
+}
+
+func TestIsOptOut(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"STOP", true},
+		{" stop! ", true},
+		{"退訂", true},
+		{"「退訂」。", true},
+		{"please stop", false},
+		{"好", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsOptOut(tt.body); got != tt.want {
+			t.Errorf("IsOptOut(%q) returned %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestInboundHandler(t *testing.T) {
+	store := NewMemorySuppressionStore()
+	var messages []*InboundMessage
+	h := &InboundHandler{
+		Suppressions: store,
+		OnMessage: func(ctx context.Context, m *InboundMessage) error {
+			messages = append(messages, m)
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/reply?msgid=M0001&srcaddr=%2B886987654321&smbody=STOP&rcvtime=20170101120000", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("InboundHandler answered %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(messages) != 1 {
+		t.Errorf("OnMessage was called %d times, want %d", len(messages), 1)
+	}
+	s, _ := store.Get(context.Background(), "0987654321")
+	want := &Suppression{Dstaddr: "0987654321", Reason: "STOP", CreatedAt: time.Date(2017, 1, 1, 12, 0, 0, 0, Taipei)}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("InboundHandler suppressed %+v, want %+v", s, want)
+	}
+}
@@ -0,0 +1,189 @@
+package mitake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// SplitBody splits a body that does not fit in a single SMS into parts that do,
+// each prefixed with its number, such as "(1/3) ". The parts are split after a
+// space or a punctuation mark when there is one in the second half of the part.
+// A body that fits in a single SMS is returned as is.
+func SplitBody(body string) []string {
+	s := CountSegments(body)
+	if s.Count <= 1 {
+		return []string{body}
+	}
+	single := gsm7SingleLength
+	if s.UCS2 {
+		single = ucs2SingleLength
+	}
+
+	runes := []rune(body)
+	n := s.Count
+	for {
+		parts := splitRunes(runes, single-len(partPrefix(n, n)), s.UCS2)
+		if len(parts) <= n {
+			for i := range parts {
+				parts[i] = partPrefix(i+1, len(parts)) + parts[i]
+			}
+			return parts
+		}
+		n = len(parts)
+	}
+}
+
+func partPrefix(i, n int) string {
+	return fmt.Sprintf("(%d/%d) ", i, n)
+}
+
+// splitRunes splits runes into parts of at most capacity characters of the encoding.
+func splitRunes(runes []rune, capacity int, ucs2 bool) []string {
+	var parts []string
+	for len(runes) > 0 {
+		length, end, lastBreak := 0, 0, 0
+		for end < len(runes) {
+			n := gsm7Length(runes[end])
+			if ucs2 {
+				n = utf16.RuneLen(runes[end])
+			}
+			if length+n > capacity {
+				break
+			}
+			length += n
+			end++
+			if r := runes[end-1]; unicode.IsSpace(r) || unicode.IsPunct(r) {
+				lastBreak = end
+			}
+		}
+		if end < len(runes) && lastBreak > end/2 {
+			end = lastBreak
+		}
+		if part := strings.TrimSpace(string(runes[:end])); part != "" {
+			parts = append(parts, part)
+		}
+		runes = runes[end:]
+	}
+	return parts
+}
+
+// LongMessageResponse represents the response of a message sent with SendLong.
+type LongMessageResponse struct {
+	ClientID     string           // The ClientID of the logical message
+	Parts        []*MessageResult // The result of each part, in order
+	AccountPoint int
+}
+
+// Msgids returns the msgids of the parts.
+func (r *LongMessageResponse) Msgids() []string {
+	var msgids []string
+	for _, part := range r.Parts {
+		if part.Msgid != "" {
+			msgids = append(msgids, part.Msgid)
+		}
+	}
+	return msgids
+}
+
+// Accepted reports whether every part was accepted by Mitake.
+func (r *LongMessageResponse) Accepted() bool {
+	for _, part := range r.Parts {
+		if part.StatusCode.Class() != StatusClassSuccess {
+			return false
+		}
+	}
+	return len(r.Parts) > 0
+}
+
+// SendLong sends a SMS whose body may not fit in a single segment. The body is
+// split by SplitBody, and the parts are sent as separate SMS in a single bulk
+// request, with the ClientIDs "<ClientID>-1", "<ClientID>-2" and so on. A message
+// without ClientID gets one from the ClientIDs of the client, or a random one.
+// When the suffixes do not fit in MaxClientIDLength, as with a UUID, the parts
+// get a hash of the ClientID instead, so that they still get the same ClientIDs
+// when the message is sent again.
+//
+// The client-side policies are applied to the message as a whole, so that its
+// parts are all sent or all dropped. The retry policy is not applied. When the
+// response lacks the result of a part, the results of the parts before it are
+// returned with the error.
+func (c *Client) SendLong(ctx context.Context, params MessageParams) (*LongMessageResponse, error) {
+	c.fillClientID(&params.Message)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if params.ClientID == "" {
+		params.ClientID = newClientID()
+	}
+
+	p, err := c.prepareMessage(ctx, params.Message)
+	if err != nil {
+		return nil, err
+	}
+	response := &LongMessageResponse{ClientID: params.ClientID}
+	if p.result != nil {
+		response.Parts = []*MessageResult{p.result}
+		return response, nil
+	}
+
+	bodies := SplitBody(p.Smbody)
+	messages := make([]Message, len(bodies))
+	for i, body := range bodies {
+		messages[i] = p.Message
+		messages[i].Smbody = body
+		if len(bodies) > 1 {
+			messages[i].ClientID = partClientID(params.ClientID, i+1, len(bodies))
+		}
+	}
+	opts := BatchMessagesParams{
+		Encoding:           params.Encoding,
+		ObjectID:           params.ObjectID,
+		HideDeductedPoints: params.HideDeductedPoints,
+		ShowDeductedPoints: params.ShowDeductedPoints,
+		Messages:           messages,
+	}
+	if err := opts.Validate(); err != nil {
+		p.settle(ctx, nil)
+		return nil, err
+	}
+
+	resp, err := c.sendBatch(ctx, opts)
+	if err != nil {
+		p.settle(ctx, nil)
+		return nil, err
+	}
+	// The message counts as sent for the policies when any of its parts is.
+	var accepted *MessageResult
+	defer func() { p.settle(ctx, accepted) }()
+	response.AccountPoint = resp.AccountPoint
+	err = c.recordObject(ctx, params.ObjectID, resp)
+	for i, result := range matchResults(messages, resp) {
+		if result == nil {
+			return response, &UnexpectedResponseError{Reason: "no result for ClientID " + messages[i].ClientID}
+		}
+		p.apply(result)
+		if accepted == nil && result.StatusCode.Class() == StatusClassSuccess {
+			accepted = result
+		}
+		response.Parts = append(response.Parts, result)
+	}
+	return response, err
+}
+
+// partClientID returns the ClientID of the part i of n of the message with
+// clientID. A clientID too long for the suffix is replaced by its hex SHA-256,
+// truncated to leave room for the suffix of the last part.
+func partClientID(clientID string, i, n int) string {
+	room := MaxClientIDLength - len(fmt.Sprintf("-%d", n))
+	if utf8.RuneCountInString(clientID) > room {
+		sum := sha256.Sum256([]byte(clientID))
+		clientID = hex.EncodeToString(sum[:])[:room]
+	}
+	return fmt.Sprintf("%s-%d", clientID, i)
+}
@@ -0,0 +1,143 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitBody(t *testing.T) {
+	short := "Hello"
+	if got := SplitBody(short); !reflect.DeepEqual(got, []string{short}) {
+		t.Errorf("SplitBody(%q) returned %q", short, got)
+	}
+
+	for _, body := range []string{
+		strings.Repeat("Hello world. ", 30),
+		strings.Repeat("你好，世界。", 30),
+		strings.Repeat("x", 500),
+	} {
+		parts := SplitBody(body)
+		if len(parts) < 2 {
+			t.Errorf("SplitBody returned %d parts for %d characters", len(parts), len([]rune(body)))
+		}
+		var joined []string
+		for i, part := range parts {
+			if s := CountSegments(part); s.Count != 1 {
+				t.Errorf("SplitBody returned part %q of %d segments", part, s.Count)
+			}
+			prefix := fmt.Sprintf("(%d/%d) ", i+1, len(parts))
+			if !strings.HasPrefix(part, prefix) {
+				t.Errorf("SplitBody returned part %q without prefix %q", part, prefix)
+			}
+			joined = append(joined, strings.TrimPrefix(part, prefix))
+		}
+		// The spaces at the boundaries of the parts are trimmed.
+		if strings.ReplaceAll(strings.Join(joined, ""), " ", "") != strings.ReplaceAll(body, " ", "") {
+			t.Errorf("SplitBody lost characters of %q", body)
+		}
+	}
+}
+
+func TestSplitBody_safeBoundary(t *testing.T) {
+	parts := SplitBody(strings.Repeat("word ", 40))
+	for _, part := range parts {
+		if !strings.HasSuffix(part, "word") {
+			t.Errorf("SplitBody split a word in part %q", part)
+		}
+	}
+}
+
+func TestClient_SendLong(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	body := strings.Repeat("你好，世界。", 20)
+	resp, err := client.SendLong(context.Background(), MessageParams{
+		Message: Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: body},
+	})
+	if err != nil {
+		t.Fatalf("SendLong returned unexpected error: %v", err)
+	}
+
+	if want := []string{"#0aab-1", "#0aab-2"}; !reflect.DeepEqual(resp.Msgids(), want) {
+		t.Errorf("SendLong returned msgids %v, want %v", resp.Msgids(), want)
+	}
+	if !resp.Accepted() || resp.ClientID != "0aab" || resp.AccountPoint != 99 {
+		t.Errorf("SendLong returned %+v", resp)
+	}
+	if len(bodies) != 1 {
+		t.Errorf("SendLong made %d requests, want %d", len(bodies), 1)
+	}
+}
+
+func TestClient_SendLong_longClientID(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.ClientIDs = UUIDv7Generator{}
+
+	params := MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: strings.Repeat("你好，世界。", 20)}}
+	resp, err := client.SendLong(context.Background(), params)
+	if err != nil {
+		t.Fatalf("SendLong returned unexpected error: %v", err)
+	}
+	if len(resp.ClientID) != 36 || len(resp.Parts) != 2 {
+		t.Fatalf("SendLong returned %+v", resp)
+	}
+	for i, part := range resp.Parts {
+		if len(part.ClientID) > MaxClientIDLength || part.ClientID != partClientID(resp.ClientID, i+1, 2) {
+			t.Errorf("SendLong sent part %d with ClientID %q", i+1, part.ClientID)
+		}
+	}
+}
+
+func TestClient_SendLong_missingPart(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[0aab-1]\nmsgid=#0aab-1\nstatuscode=1\nAccountPoint=99\n")
+	})
+
+	resp, err := client.SendLong(context.Background(), MessageParams{
+		Message: Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: strings.Repeat("你好，世界。", 20)},
+	})
+	want := &UnexpectedResponseError{Reason: "no result for ClientID 0aab-2"}
+	if !errors.Is(err, want) {
+		t.Errorf("SendLong returned error %v, want %v", err, want)
+	}
+	if resp == nil || !reflect.DeepEqual(resp.Msgids(), []string{"#0aab-1"}) {
+		t.Errorf("SendLong returned %+v, want the result of the first part", resp)
+	}
+}
+
+func TestClient_SendLong_dropped(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("SendLong sent a dropped message")
+	})
+	client.AllowList = &AllowList{Numbers: []string{"0912345678"}}
+
+	resp, err := client.SendLong(context.Background(), MessageParams{
+		Message: Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: strings.Repeat("x", 500)},
+	})
+	if err != nil {
+		t.Fatalf("SendLong returned unexpected error: %v", err)
+	}
+	want := []*MessageResult{{ClientID: "0aab", StatusCode: StatusRecipientNotAllowed}}
+	if !reflect.DeepEqual(resp.Parts, want) {
+		t.Errorf("SendLong returned parts %+v, want %+v", resp.Parts, want)
+	}
+}
@@ -1,5 +1,10 @@
 package mitake
 
+import (
+	"strconv"
+	"time"
+)
+
 // StatusCode of Mitake API.
 type StatusCode string
 
@@ -44,6 +49,15 @@ const (
 	StatusReservationCanceled    = StatusCode("9")
 )
 
+// List of synthetic status codes, which the client sets on the results of messages
+// it did not send because of a client-side policy. Mitake never returns them.
+const (
+	StatusRecipientNotAllowed = StatusCode("not_allowed")
+	StatusThrottled           = StatusCode("throttled")
+	StatusDuplicateSuppressed = StatusCode("duplicate")
+	StatusOptedOut            = StatusCode("opted_out")
+)
+
 var statusCodeMap = map[StatusCode]string{
 	StatusServiceError:                    "系統發生錯誤，請聯絡三竹資訊窗口人員",
 	StatusSMSTemporarilyUnavailable:       "簡訊發送功能暫時停止服務，請稍候再試",
@@ -78,14 +92,123 @@ var statusCodeMap = map[StatusCode]string{
 	StatusSMSDisable:             "簡訊已停用",
 	StatusDeliveryTimeout:        "逾時無送達",
 	StatusReservationCanceled:    "預約已取消",
+
+	StatusRecipientNotAllowed: "收件人不在允許清單",
+	StatusThrottled:           "超過收件人發送頻率限制",
+	StatusDuplicateSuppressed: "重複簡訊已略過",
+	StatusOptedOut:            "收件人已退訂",
+}
+
+// StatusClass groups status codes by how the caller should react to them.
+type StatusClass int
+
+// List of status classes.
+const (
+	StatusClassUnknown         StatusClass = iota
+	StatusClassSuccess                     // The message is reserved, accepted, or delivered
+	StatusClassUnavailable                 // The service is temporarily unavailable, try again later
+	StatusClassAuthentication              // The credentials or connection are rejected
+	StatusClassAccount                     // The account cannot send messages
+	StatusClassRequest                     // The request is invalid or has no data
+	StatusClassDeliveryFailure             // The message could not be delivered
+	StatusClassCanceled                    // The reservation is canceled
+	StatusClassSuppressed                  // The client did not send the message because of a policy
+)
+
+var statusClassNames = map[StatusClass]string{
+	StatusClassUnknown:         "unknown",
+	StatusClassSuccess:         "success",
+	StatusClassUnavailable:     "unavailable",
+	StatusClassAuthentication:  "authentication",
+	StatusClassAccount:         "account",
+	StatusClassRequest:         "request",
+	StatusClassDeliveryFailure: "delivery failure",
+	StatusClassCanceled:        "canceled",
+	StatusClassSuppressed:      "suppressed",
+}
+
+func (c StatusClass) String() string {
+	return statusClassNames[c]
+}
+
+// Class returns the class of the status code.
+func (c StatusCode) Class() StatusClass {
+	switch c {
+	case StatusReservationForDelivery, StatusCarrierAccepted, StatusCarrierAccepted2, StatusCarrierAccepted3, StatusDelivered:
+		return StatusClassSuccess
+	case StatusServiceError, StatusSMSTemporarilyUnavailable, StatusSMSTemporarilyUnavailableB,
+		StatusServiceTemporarilyUnavailable, StatusReachedMaxConcurrentConnections:
+		return StatusClassUnavailable
+	case StatusUsernameRequired, StatusPasswordRequired, StatusUsernameOrPasswordError, StatusInvalidConnectionAddress,
+		StatusChangePasswordRequired, StatusPasswordExpired, StatusPermissionDenied:
+		return StatusClassAuthentication
+	case StatusAccountExpired, StatusAccountDisabled, StatusAccountingFailure:
+		return StatusClassAccount
+	case StatusSMSExpired, StatusSMSBodyEmpty, StatusInvalidPhoneNumber, StatusQueryCountExceedsLimit,
+		StatusFileToLargeToSend, StatusInvalidParameter, StatusNoDataFound:
+		return StatusClassRequest
+	case StatusContentError, StatusPhoneNumberError, StatusSMSDisable, StatusDeliveryTimeout:
+		return StatusClassDeliveryFailure
+	case StatusReservationCanceled:
+		return StatusClassCanceled
+	case StatusRecipientNotAllowed, StatusThrottled, StatusDuplicateSuppressed, StatusOptedOut:
+		return StatusClassSuppressed
+	}
+	return StatusClassUnknown
+}
+
+// IsSent reports whether the status shows the message has left the reservation
+// queue, so that it can no longer be canceled.
+func (c StatusCode) IsSent() bool {
+	switch c {
+	case StatusCarrierAccepted, StatusCarrierAccepted2, StatusCarrierAccepted3, StatusDelivered,
+		StatusContentError, StatusPhoneNumberError, StatusSMSDisable, StatusDeliveryTimeout:
+		return true
+	}
+	return false
 }
 
 type Message struct {
 	ClientID string // A unique identifier from client to identify SMS message
 	Dstaddr  string // Required, Destination phone number
-	Smbody   string // Required, The text of the message you want to send, use ASCII code 6 to represent a new line
+	Smbody   string // Required, The text of the message you want to send, use ASCII code 6 to represent a new line, line breaks are converted in batch sends
 	Dlvtime  string // Scheduled delivery time, format: YYYYMMDDHHMMSS
-	Vldtime  string // Validity period, format: YYYYMMDDHHMMSS
+	Vldtime  string // Validity period, format: YYYYMMDDHHMMSS, or a number of seconds such as "3600"
 	Destname string // Destination receiver name
 	Response string // Callback URL to receive the delivery receipt of the message
+
+	// Category selects the Policy the client applies to the message. It is not
+	// sent to Mitake.
+	Category Category
+}
+
+// timeLayout is the layout of Dlvtime and Vldtime.
+const timeLayout = "20060102150405"
+
+// Taipei is the time zone of the times of the Mitake API. Taiwan does not observe
+// daylight saving time.
+var Taipei = time.FixedZone("Asia/Taipei", 8*60*60)
+
+// FormatTime formats t in the YYYYMMDDHHMMSS layout of Dlvtime and Vldtime.
+func FormatTime(t time.Time) string {
+	return t.In(Taipei).Format(timeLayout)
+}
+
+// ParseTime parses a time in the YYYYMMDDHHMMSS layout of Dlvtime and Vldtime.
+func ParseTime(s string) (time.Time, error) {
+	return time.ParseInLocation(timeLayout, s, Taipei)
+}
+
+// vldtimeSeconds returns the validity period of a Vldtime given as a number of
+// seconds rather than a time, which is relative to the delivery and so does not
+// move with it.
+func vldtimeSeconds(vldtime string) (time.Duration, bool) {
+	if len(vldtime) == len(timeLayout) {
+		return 0, false
+	}
+	seconds, err := strconv.ParseUint(vldtime, 10, 32)
+	if err != nil || seconds == 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
 }
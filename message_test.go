@@ -1,6 +1,9 @@
 package mitake
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestStatusCode_String(t *testing.T) {
 	actual := StatusServiceError.String()
@@ -9,3 +12,39 @@ func TestStatusCode_String(t *testing.T) {
 		t.Error("StatusServiceError.String() returned unexpected value")
 	}
 }
+
+func TestStatusCode_IsSent(t *testing.T) {
+	if !StatusDelivered.IsSent() {
+		t.Error("StatusDelivered.IsSent() returned false")
+	}
+	if StatusReservationForDelivery.IsSent() {
+		t.Error("StatusReservationForDelivery.IsSent() returned true")
+	}
+}
+
+func TestStatusCode_Class(t *testing.T) {
+	for code := range statusCodeMap {
+		if code.Class() == StatusClassUnknown {
+			t.Errorf("StatusCode(%q).Class() returned %v", code, StatusClassUnknown)
+		}
+	}
+	if got := StatusCode("?").Class(); got != StatusClassUnknown {
+		t.Errorf(`StatusCode("?").Class() returned %v, want %v`, got, StatusClassUnknown)
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tm := time.Date(2017, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	if got, want := FormatTime(tm), "20170101090000"; got != want {
+		t.Errorf("FormatTime returned %v, want %v", got, want)
+	}
+
+	parsed, err := ParseTime("20170101090000")
+	if err != nil {
+		t.Fatalf("ParseTime returned unexpected error: %v", err)
+	}
+	if !parsed.Equal(tm) {
+		t.Errorf("ParseTime returned %v, want %v", parsed, tm)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"sync"
+	"text/template"
 )
 
 const (
@@ -46,6 +48,55 @@ type Client struct {
 
 	BaseURL   *url.URL
 	UserAgent string
+
+	// Defaults holds the default settings of the messages sent by the client.
+	Defaults MessageDefaults
+
+	// ClientIDs, when set, generates the ClientIDs of the messages sent without one,
+	// see ClientIDGenerator.
+	ClientIDs ClientIDGenerator
+
+	// Objects, when set, records the msgids of every message sent with an ObjectID.
+	Objects ObjectRegistry
+
+	// DryRun, when set, records the requests instead of sending them, see DryRun.
+	DryRun *DryRun
+
+	// Breaker, when set, fails calls fast with ErrCircuitOpen while Mitake is
+	// degraded. Transport errors and the status codes of StatusClassUnavailable
+	// but StatusReachedMaxConcurrentConnections, in the responses of every
+	// endpoint, count as failures.
+	Breaker *CircuitBreaker
+
+	// AllowList, when set, restricts the recipients of Send, SendBatch and
+	// SendBatchFrom, see AllowList.
+	AllowList *AllowList
+
+	// Policies selects the client-side policies by the Category of the messages,
+	// see Policy. Messages whose Category has no policy get the QuietHours and
+	// Guard of the client.
+	Policies map[Category]*Policy
+
+	// Retry, when set, retries the messages of Send whose Category has no policy,
+	// see RetryPolicy. The other methods do not retry.
+	Retry *RetryPolicy
+
+	// Suppressions, when set, holds the recipients who opted out. Their messages
+	// are dropped with the StatusOptedOut status, unless they are of a
+	// transactional Category or their Policy skips the suppressions.
+	Suppressions SuppressionStore
+
+	// Guard, when set, throttles and deduplicates the messages of Send, SendBatch
+	// and SendBatchFrom per recipient, see Guard.
+	Guard *Guard
+
+	responseMu   sync.Mutex
+	responseText string             // The text of responseTmpl
+	responseTmpl *template.Template // The parsed Response of the Defaults
+
+	// QuietHours, when set, defers the messages of Send, SendBatch and SendBatchFrom
+	// that would be delivered during the quiet hours, see QuietHours.
+	QuietHours *QuietHours
 }
 
 // checkErrorResponse checks the API response for errors.
@@ -65,7 +116,14 @@ func checkErrorResponse(r *http.Response) error {
 // If the returned error is nil, the Response will contain a non-nil
 // Body which the user is expected to close.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	resp, err := c.client.Do(req)
+	do := c.client.Do
+	if c.DryRun != nil {
+		do = c.DryRun.do
+	}
+	if c.Breaker != nil {
+		return doWithBreaker(c.Breaker, req, do)
+	}
+	resp, err := do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +180,7 @@ func (c *Client) buildDefaultQuery() url.Values {
 	q := url.Values{}
 	q.Set("username", c.username)
 	q.Set("password", c.password)
-	return url.Values{}
+	return q
 }
 
 // ParameterError represents an error caused by invalid parameters.
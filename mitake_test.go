@@ -43,6 +43,16 @@ func testRequestURI(t *testing.T, r *http.Request, want string) {
 	}
 }
 
+func testCredentials(t *testing.T, r *http.Request) {
+	q := r.URL.Query()
+	if got := q.Get("username"); got != "username" {
+		t.Errorf("Request username is %q, want %q", got, "username")
+	}
+	if got := q.Get("password"); got != "password" {
+		t.Errorf("Request password is %q, want %q", got, "password")
+	}
+}
+
 func testFormData(t *testing.T, r *http.Request, want url.Values) {
 	if err := r.ParseForm(); err != nil {
 		t.Errorf("Request parameters error: %v", err)
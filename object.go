@@ -0,0 +1,59 @@
+package mitake
+
+import (
+	"context"
+	"sync"
+)
+
+// ObjectRegistry records the msgids sent under each ObjectID, so that a whole batch
+// can later be looked up, for example to cancel a scheduled campaign.
+type ObjectRegistry interface {
+	// Record adds msgids to the batch named objectID.
+	Record(ctx context.Context, objectID string, msgids []string) error
+	// MessageIDs returns the msgids recorded for the batch named objectID.
+	MessageIDs(ctx context.Context, objectID string) ([]string, error)
+}
+
+// MemoryObjectRegistry is an ObjectRegistry that keeps msgids in memory.
+type MemoryObjectRegistry struct {
+	mu      sync.RWMutex
+	objects map[string][]string
+}
+
+// NewMemoryObjectRegistry returns an empty MemoryObjectRegistry.
+func NewMemoryObjectRegistry() *MemoryObjectRegistry {
+	return &MemoryObjectRegistry{objects: make(map[string][]string)}
+}
+
+// Record implements ObjectRegistry.
+func (r *MemoryObjectRegistry) Record(_ context.Context, objectID string, msgids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.objects[objectID] = append(r.objects[objectID], msgids...)
+	return nil
+}
+
+// MessageIDs implements ObjectRegistry.
+func (r *MemoryObjectRegistry) MessageIDs(_ context.Context, objectID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.objects[objectID]...), nil
+}
+
+// recordObject records the msgids of resp under objectID when the client has an
+// ObjectRegistry.
+func (c *Client) recordObject(ctx context.Context, objectID string, resp *MessageResponse) error {
+	if c.Objects == nil || objectID == "" {
+		return nil
+	}
+	var msgids []string
+	for _, result := range resp.Results {
+		if result.Msgid != "" {
+			msgids = append(msgids, result.Msgid)
+		}
+	}
+	if len(msgids) == 0 {
+		return nil
+	}
+	return c.Objects.Record(ctx, objectID, msgids)
+}
@@ -0,0 +1,264 @@
+// Package otp sends one-time passcodes through Mitake, and verifies them.
+//
+// Example usage:
+//
+//	m := &otp.Manager{Sender: client, Response: "https://example.com/receipts"}
+//	if err := m.Send(ctx, "login:42", "0987654321"); err != nil { ... }
+//	...
+//	if err := m.Verify(ctx, "login:42", input); err != nil { ... }
+package otp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minchao/go-mitake/v2"
+)
+
+// Errors returned by a Manager.
+var (
+	ErrNotFound        = errors.New("otp: code not found")
+	ErrExpired         = errors.New("otp: code expired")
+	ErrInvalidCode     = errors.New("otp: invalid code")
+	ErrTooManyAttempts = errors.New("otp: too many attempts")
+	ErrThrottled       = errors.New("otp: too many codes sent to the recipient")
+)
+
+// SendError is returned when Mitake, or a client-side policy, did not accept the
+// message of a code.
+type SendError struct {
+	Status mitake.StatusCode
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("otp: code not sent: %s (%s)", string(e.Status), e.Status)
+}
+
+const (
+	defaultLength      = 6
+	defaultTTL         = 5 * time.Minute
+	defaultMaxAttempts = 5
+	defaultBody        = "Your verification code is {{code}}, valid for {{minutes}} minutes."
+)
+
+// Manager sends codes and verifies them. Codes are identified by a key chosen by
+// the caller, for example the purpose and the user ID, and sending a new code for a
+// key replaces the previous one.
+//
+// Resends are throttled by the Guard of the mitake.Client used as Sender: a code
+// dropped with the StatusThrottled or StatusDuplicateSuppressed status fails with
+// ErrThrottled.
+type Manager struct {
+	Sender mitake.Sender
+	// Store holds the hashed codes, defaults to a MemoryStore.
+	Store Store
+	// Template renders the body, with the {{code}} and {{minutes}} variables.
+	// Defaults to "Your verification code is {{code}}, valid for {{minutes}} minutes."
+	Template *mitake.Template
+
+	Length      int           // Number of digits of a code, defaults to 6
+	TTL         time.Duration // How long a code is valid, also used as Vldtime, defaults to 5m
+	MaxAttempts int           // Verifications allowed per code, defaults to 5
+
+	// Response is the callback URL of the delivery receipts, to be handled by
+	// HandleReceipt.
+	Response string
+	// OnDeliveryFailure, when set, is called by HandleReceipt for a code that could
+	// not be delivered, so that another channel can be offered to the user.
+	OnDeliveryFailure func(ctx context.Context, failure DeliveryFailure)
+
+	once sync.Once
+	now  func() time.Time // For testing
+}
+
+// DeliveryFailure reports a code that could not be delivered.
+type DeliveryFailure struct {
+	Key     string
+	Dstaddr string
+	Msgid   string
+	Status  mitake.StatusCode
+}
+
+// Send generates a code for the key, and sends it to dstaddr.
+func (m *Manager) Send(ctx context.Context, key, dstaddr string) error {
+	code, err := m.generate()
+	if err != nil {
+		return err
+	}
+	tmpl := m.Template
+	if tmpl == nil {
+		if tmpl, err = mitake.ParseSimpleTemplate(defaultBody); err != nil {
+			return err
+		}
+	}
+	ttl := m.ttl()
+	body, err := tmpl.Render(map[string]string{
+		"code":    code,
+		"minutes": strconv.Itoa(int((ttl + time.Minute - 1) / time.Minute)),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := m.clock()
+	resp, err := m.Sender.Send(ctx, mitake.MessageParams{Message: mitake.Message{
+		Dstaddr:  dstaddr,
+		Smbody:   body,
+		Vldtime:  mitake.FormatTime(now.Add(ttl)),
+		Response: m.Response,
+		Category: mitake.CategoryOTP,
+	}})
+	if err != nil {
+		return err
+	}
+	if len(resp.Results) == 0 {
+		return &mitake.UnexpectedResponseError{Reason: "no message result"}
+	}
+	result := resp.Results[0]
+	switch {
+	case result.StatusCode == mitake.StatusThrottled, result.StatusCode == mitake.StatusDuplicateSuppressed:
+		return ErrThrottled
+	case result.StatusCode.Class() != mitake.StatusClassSuccess:
+		return &SendError{Status: result.StatusCode}
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	return m.store().Save(ctx, key, &Entry{
+		Hash:      hash(salt, code),
+		Salt:      salt,
+		ExpiresAt: now.Add(ttl),
+		Msgid:     result.Msgid,
+		Dstaddr:   dstaddr,
+	})
+}
+
+// Verify checks the code entered by the user for the key. A verified code is
+// deleted, so that it can be used only once.
+//
+// The attempt is counted before the code is compared, and the code is deleted
+// only if it is still the one compared, so that concurrent verifications can
+// neither exceed MaxAttempts nor use a code twice.
+func (m *Manager) Verify(ctx context.Context, key, code string) error {
+	s := m.store()
+	e, err := s.IncrementAttempts(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !m.clock().Before(e.ExpiresAt) {
+		_ = s.Delete(ctx, key)
+		return ErrExpired
+	}
+	if e.Attempts > m.maxAttempts() {
+		return ErrTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare(hash(e.Salt, code), e.Hash) == 1 {
+		deleted, err := s.CompareAndDelete(ctx, key, e.Hash)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			// Verified, or replaced by a new code, in the meantime.
+			return ErrNotFound
+		}
+		return nil
+	}
+	if e.Attempts >= m.maxAttempts() {
+		return ErrTooManyAttempts
+	}
+	return ErrInvalidCode
+}
+
+// HandleReceipt processes the delivery receipt of a code, and calls
+// OnDeliveryFailure if the code could not be delivered. Receipts of messages that
+// are not codes of the Manager are ignored.
+//
+// Example usage:
+//
+//	func Callback(w http.ResponseWriter, r *http.Request) {
+//		receipt, err := mitake.ParseMessageReceipt(r)
+//		if err != nil { ... }
+//		err = m.HandleReceipt(r.Context(), receipt)
+//	}
+func (m *Manager) HandleReceipt(ctx context.Context, receipt *mitake.MessageReceipt) error {
+	status := receipt.Statusstring
+	if status.Class() != mitake.StatusClassDeliveryFailure && status != mitake.StatusSMSExpired {
+		return nil
+	}
+	key, e, err := m.store().LoadByMsgid(ctx, receipt.Msgid)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if m.OnDeliveryFailure != nil {
+		m.OnDeliveryFailure(ctx, DeliveryFailure{Key: key, Dstaddr: e.Dstaddr, Msgid: receipt.Msgid, Status: status})
+	}
+	return nil
+}
+
+// generate returns a random code of Length digits.
+func (m *Manager) generate() (string, error) {
+	length := m.Length
+	if length <= 0 {
+		length = defaultLength
+	}
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+func (m *Manager) store() Store {
+	m.once.Do(func() {
+		if m.Store == nil {
+			m.Store = NewMemoryStore()
+		}
+		if s, ok := m.Store.(*MemoryStore); ok {
+			s.setClock(m.clock)
+		}
+	})
+	return m.Store
+}
+
+func (m *Manager) ttl() time.Duration {
+	if m.TTL > 0 {
+		return m.TTL
+	}
+	return defaultTTL
+}
+
+func (m *Manager) maxAttempts() int {
+	if m.MaxAttempts > 0 {
+		return m.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+func (m *Manager) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+func hash(salt []byte, code string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(code))
+	return h.Sum(nil)
+}
@@ -0,0 +1,200 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minchao/go-mitake/v2"
+)
+
+// fakeSender records the messages sent, and returns status for each of them.
+type fakeSender struct {
+	status   mitake.StatusCode
+	messages []mitake.Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, params mitake.MessageParams) (*mitake.MessageResponse, error) {
+	s.messages = append(s.messages, params.Message)
+	return &mitake.MessageResponse{Results: []*mitake.MessageResult{
+		{Msgid: "#00000000" + string(rune('0'+len(s.messages))), StatusCode: s.status},
+	}}, nil
+}
+
+func (s *fakeSender) SendBatch(ctx context.Context, params mitake.BatchMessagesParams) (*mitake.MessageResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+func TestManager(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, mitake.Taipei)
+	sender := &fakeSender{status: mitake.StatusCarrierAccepted}
+	m := &Manager{Sender: sender, MaxAttempts: 2, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	if err := m.Send(ctx, "login", "0987654321"); err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	message := sender.messages[0]
+	if want := "20170101000500"; message.Vldtime != want {
+		t.Errorf("Send sent Vldtime %v, want %v", message.Vldtime, want)
+	}
+	code := codePattern.FindString(message.Smbody)
+	if code == "" {
+		t.Fatalf("Send sent body %q without code", message.Smbody)
+	}
+	if want := "Your verification code is " + code + ", valid for 5 minutes."; message.Smbody != want {
+		t.Errorf("Send sent body %q, want %q", message.Smbody, want)
+	}
+
+	if err := m.Verify(ctx, "login", "x"+code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify returned error %v, want %v", err, ErrInvalidCode)
+	}
+	if err := m.Verify(ctx, "login", code); err != nil {
+		t.Errorf("Verify returned unexpected error: %v", err)
+	}
+	if err := m.Verify(ctx, "login", code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Verify of a used code returned error %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestManager_Verify_limits(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, mitake.Taipei)
+	sender := &fakeSender{status: mitake.StatusCarrierAccepted}
+	m := &Manager{Sender: sender, MaxAttempts: 2, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	_ = m.Send(ctx, "login", "0987654321")
+	code := codePattern.FindString(sender.messages[0].Smbody)
+	for _, want := range []error{ErrInvalidCode, ErrTooManyAttempts, ErrTooManyAttempts} {
+		if err := m.Verify(ctx, "login", "wrong"); !errors.Is(err, want) {
+			t.Errorf("Verify returned error %v, want %v", err, want)
+		}
+	}
+	if err := m.Verify(ctx, "login", code); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Verify returned error %v, want %v", err, ErrTooManyAttempts)
+	}
+
+	_ = m.Send(ctx, "login", "0987654321")
+	code = codePattern.FindString(sender.messages[1].Smbody)
+	now = now.Add(5 * time.Minute)
+	if err := m.Verify(ctx, "login", code); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify returned error %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestManager_Verify_concurrent(t *testing.T) {
+	sender := &fakeSender{status: mitake.StatusCarrierAccepted}
+	m := &Manager{Sender: sender, MaxAttempts: 3}
+	ctx := context.Background()
+
+	_ = m.Send(ctx, "login", "0987654321")
+	code := codePattern.FindString(sender.messages[0].Smbody)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified int
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Verify(ctx, "login", code); err == nil {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if verified != 1 {
+		t.Errorf("Verify accepted the code %d times, want 1", verified)
+	}
+
+	_ = m.Send(ctx, "login", "0987654321")
+	code = codePattern.FindString(sender.messages[1].Smbody)
+	var invalid int
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Verify(ctx, "login", "wrong"); errors.Is(err, ErrInvalidCode) {
+				mu.Lock()
+				invalid++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if invalid != 2 {
+		t.Errorf("Verify compared %d wrong codes, want 2", invalid)
+	}
+	if err := m.Verify(ctx, "login", code); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Verify returned error %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestManager_MemoryStoreClock(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, mitake.Taipei)
+	sender := &fakeSender{status: mitake.StatusCarrierAccepted}
+	m := &Manager{Sender: sender, Store: NewMemoryStore(), now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	_ = m.Send(ctx, "login", "0987654321")
+	_ = m.Send(ctx, "reset", "0987654321")
+	code := codePattern.FindString(sender.messages[0].Smbody)
+	if err := m.Verify(ctx, "login", code); err != nil {
+		t.Errorf("Verify returned unexpected error: %v", err)
+	}
+}
+
+func TestManager_Send_throttled(t *testing.T) {
+	for _, tt := range []struct {
+		status mitake.StatusCode
+		want   error
+	}{
+		{mitake.StatusThrottled, ErrThrottled},
+		{mitake.StatusDuplicateSuppressed, ErrThrottled},
+		{mitake.StatusInvalidPhoneNumber, &SendError{Status: mitake.StatusInvalidPhoneNumber}},
+	} {
+		m := &Manager{Sender: &fakeSender{status: tt.status}, Store: NewMemoryStore()}
+		err := m.Send(context.Background(), "login", "0987654321")
+		if err == nil || err.Error() != tt.want.Error() {
+			t.Errorf("Send returned error %v, want %v", err, tt.want)
+		}
+		if _, err := m.Store.Load(context.Background(), "login"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Send stored a code that was not sent")
+		}
+	}
+}
+
+func TestManager_HandleReceipt(t *testing.T) {
+	var failures []DeliveryFailure
+	m := &Manager{
+		Sender: &fakeSender{status: mitake.StatusCarrierAccepted},
+		OnDeliveryFailure: func(ctx context.Context, failure DeliveryFailure) {
+			failures = append(failures, failure)
+		},
+	}
+	ctx := context.Background()
+	_ = m.Send(ctx, "login", "0987654321")
+
+	for _, receipt := range []*mitake.MessageReceipt{
+		{Msgid: "#000000001", Statusstring: mitake.StatusDelivered},
+		{Msgid: "#000000002", Statusstring: mitake.StatusPhoneNumberError},
+		{Msgid: "#000000001", Statusstring: mitake.StatusSMSExpired},
+	} {
+		if err := m.HandleReceipt(ctx, receipt); err != nil {
+			t.Fatalf("HandleReceipt returned unexpected error: %v", err)
+		}
+	}
+
+	want := DeliveryFailure{Key: "login", Dstaddr: "0987654321", Msgid: "#000000001", Status: mitake.StatusSMSExpired}
+	if len(failures) != 1 || failures[0] != want {
+		t.Errorf("OnDeliveryFailure was called with %+v, want %+v", failures, want)
+	}
+}
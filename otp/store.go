@@ -0,0 +1,152 @@
+package otp
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is a code as held by a Store. The code itself is not stored, only its
+// salted hash.
+type Entry struct {
+	Hash      []byte
+	Salt      []byte
+	ExpiresAt time.Time
+	Attempts  int    // Failed verifications
+	Msgid     string // The msgid of the message of the code
+	Dstaddr   string
+}
+
+// Store holds the codes of a Manager by key. It must be safe for concurrent use.
+type Store interface {
+	// Save stores the entry under key, replacing any previous one.
+	Save(ctx context.Context, key string, e *Entry) error
+	// Load returns the entry of key, or ErrNotFound.
+	Load(ctx context.Context, key string) (*Entry, error)
+	// LoadByMsgid returns the key and the entry of a msgid, or ErrNotFound.
+	LoadByMsgid(ctx context.Context, msgid string) (string, *Entry, error)
+	// Delete deletes the entry of key.
+	Delete(ctx context.Context, key string) error
+	// IncrementAttempts atomically adds one to the Attempts of the entry of key,
+	// and returns the updated entry, or ErrNotFound.
+	IncrementAttempts(ctx context.Context, key string) (*Entry, error)
+	// CompareAndDelete atomically deletes the entry of key if its Hash is hash, and
+	// reports whether it did.
+	CompareAndDelete(ctx context.Context, key string, hash []byte) (bool, error)
+}
+
+// MemoryStore is a Store in memory. Expired entries are deleted as new ones are
+// saved.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	msgids  map[string]string // Msgid to key
+	now     func() time.Time  // The clock of the Manager
+}
+
+// NewMemoryStore returns a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+		msgids:  make(map[string]string),
+	}
+}
+
+// Save stores the entry under key.
+func (s *MemoryStore) Save(_ context.Context, key string, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	for k, entry := range s.entries {
+		if now.After(entry.ExpiresAt) {
+			s.delete(k)
+		}
+	}
+	s.delete(key)
+	s.entries[key] = *e
+	if e.Msgid != "" {
+		s.msgids[e.Msgid] = key
+	}
+	return nil
+}
+
+// Load returns the entry of key.
+func (s *MemoryStore) Load(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+// LoadByMsgid returns the key and the entry of a msgid.
+func (s *MemoryStore) LoadByMsgid(_ context.Context, msgid string) (string, *Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.msgids[msgid]
+	if !ok {
+		return "", nil, ErrNotFound
+	}
+	e := s.entries[key]
+	return key, &e, nil
+}
+
+// Delete deletes the entry of key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(key)
+	return nil
+}
+
+// IncrementAttempts adds one to the Attempts of the entry of key.
+func (s *MemoryStore) IncrementAttempts(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	e.Attempts++
+	s.entries[key] = e
+	return &e, nil
+}
+
+// CompareAndDelete deletes the entry of key if its Hash is hash.
+func (s *MemoryStore) CompareAndDelete(_ context.Context, key string, hash []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !bytes.Equal(e.Hash, hash) {
+		return false, nil
+	}
+	s.delete(key)
+	return true, nil
+}
+
+// setClock makes the MemoryStore expire the entries with the clock of a Manager,
+// unless it already has one.
+func (s *MemoryStore) setClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.now == nil {
+		s.now = now
+	}
+}
+
+func (s *MemoryStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *MemoryStore) delete(key string) {
+	if e, ok := s.entries[key]; ok {
+		delete(s.msgids, e.Msgid)
+		delete(s.entries, key)
+	}
+}
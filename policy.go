@@ -0,0 +1,145 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Category is the kind of a message, which selects the Policy the client applies
+// to it.
+type Category string
+
+// List of message categories.
+const (
+	CategoryOTP           = Category("otp")
+	CategoryTransactional = Category("transactional")
+	CategoryMarketing     = Category("marketing")
+	CategoryAlert         = Category("alert")
+)
+
+// transactional reports whether messages of the category are awaited by the
+// user, and are exempt from QuietHours and Suppressions by default.
+func (c Category) transactional() bool {
+	return c == CategoryOTP || c == CategoryTransactional || c == CategoryAlert
+}
+
+// Policy is the client-side handling of a category of messages.
+//
+// Example usage:
+//
+//	client.Policies = map[mitake.Category]*mitake.Policy{
+//		mitake.CategoryMarketing: {
+//			QuietHours: &mitake.QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour},
+//			Guard:      &mitake.Guard{RateLimit: 1, RateWindow: 24 * time.Hour},
+//			Validity:   6 * time.Hour,
+//		},
+//		mitake.CategoryOTP: {
+//			SkipSuppressions: true,
+//			Retry:            &mitake.RetryPolicy{MaxAttempts: 3},
+//			Validity:         5 * time.Minute,
+//		},
+//	}
+type Policy struct {
+	QuietHours       *QuietHours   // Defers the messages out of the quiet hours
+	Guard            *Guard        // Throttles and deduplicates the messages per recipient
+	SkipSuppressions bool          // Sends to the recipients of the client's Suppressions
+	Retry            *RetryPolicy  // Retries the messages Mitake could not accept, only used by Send, see RetryPolicy
+	Validity         time.Duration // Sets Vldtime to the delivery time plus Validity, when it is empty
+}
+
+// policy returns the policy of the message. Messages whose category has no policy
+// get the QuietHours, Guard and Retry of the client, and messages of the transactional
+// categories are exempt from the quiet hours and the suppressions.
+func (c *Client) policy(message Message) Policy {
+	var policy Policy
+	if p, ok := c.Policies[message.Category]; ok && p != nil && message.Category != "" {
+		policy = *p
+	} else {
+		policy = Policy{QuietHours: c.QuietHours, Guard: c.Guard, Retry: c.Retry}
+		if message.Category.transactional() {
+			policy.QuietHours = nil
+			policy.SkipSuppressions = true
+		}
+	}
+	return policy
+}
+
+// applyValidity sets the Vldtime of the message, when it is empty, to its delivery
+// time plus validity.
+func applyValidity(p *preparedMessage, validity time.Duration, now time.Time) error {
+	if validity <= 0 || p.Vldtime != "" {
+		return nil
+	}
+	at := now
+	if p.Dlvtime != "" {
+		t, err := ParseTime(p.Dlvtime)
+		if err != nil {
+			return &ParameterError{Reason: fmt.Sprintf("[%s] invalid Dlvtime %q", p.ClientID, p.Dlvtime)}
+		}
+		at = t
+	}
+	p.Vldtime = FormatTime(at.Add(validity))
+	return nil
+}
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// RetryPolicy retries the sends Mitake could not accept: the results in
+// StatusClassUnavailable, and the transport errors of messages with a ClientID,
+// which Mitake does not accept twice.
+//
+// Only Send retries. SendBatch, SendBatchFrom, SendLong and Queue do not: a
+// request of several messages may be accepted in part, and only the caller knows
+// whether to send again the messages of its unavailable results.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first one, 1 or less disables retries
+	Backoff     time.Duration // Wait before the first retry, doubled after each one, defaults to 1s
+	MaxBackoff  time.Duration // Maximum wait between retries, defaults to 30s
+}
+
+// do calls send until it succeeds, returns an error that must not be retried, or
+// the attempts are exhausted.
+func (r *RetryPolicy) do(ctx context.Context, clientID string, send func() (*MessageResponse, error)) (*MessageResponse, error) {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+		if attempt >= r.MaxAttempts || !r.retryable(clientID, resp, err) {
+			return resp, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+func (r *RetryPolicy) retryable(clientID string, resp *MessageResponse, err error) bool {
+	if err != nil {
+		var pe *ParameterError
+		return clientID != "" && !errors.As(err, &pe) && !errors.Is(err, ErrCircuitOpen) &&
+			!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	for _, result := range resp.Results {
+		if result.StatusCode.Class() == StatusClassUnavailable {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClient_SendBatch_policies(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		testData(t, r, "0aab$$0987654321$$20170102080000$$20170102100000$$$$$$Sale\r\n"+
+			"2aab$$0987654321$$$$$$$$$$Code\r\n"+
+			"3aab$$0912345678$$$$$$$$$$Down\r\n")
+		_, _ = fmt.Fprint(w, "[0aab]\nmsgid=#1\nstatuscode=0\n[2aab]\nmsgid=#2\nstatuscode=1\n[3aab]\nmsgid=#3\nstatuscode=1\nAccountPoint=98\n")
+	})
+	now, _ := ParseTime("20170101230000")
+	quietHours := &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour, now: func() time.Time { return now }}
+	client.QuietHours = quietHours
+	client.Suppressions = NewMemorySuppressionStore()
+	_ = client.Suppressions.Add(context.Background(), Suppression{Dstaddr: "0912345678"})
+	client.Policies = map[Category]*Policy{
+		CategoryMarketing: {
+			QuietHours: quietHours,
+			Guard:      &Guard{RateLimit: 1, RateWindow: time.Hour},
+			Validity:   2 * time.Hour,
+		},
+	}
+
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		HideDeductedPoints: true,
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Sale", Category: CategoryMarketing},
+			{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Sale again", Category: CategoryMarketing},
+			{ClientID: "2aab", Dstaddr: "0987654321", Smbody: "Code", Category: CategoryOTP},
+			{ClientID: "3aab", Dstaddr: "0912345678", Smbody: "Down", Category: CategoryAlert},
+			{ClientID: "4aab", Dstaddr: "0912345678", Smbody: "Sale", Category: CategoryMarketing},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	var statuses []StatusCode
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.StatusCode)
+	}
+	want := []StatusCode{StatusReservationForDelivery, StatusThrottled, StatusCarrierAccepted, StatusCarrierAccepted, StatusOptedOut}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("SendBatch returned statuses %v, want %v", statuses, want)
+	}
+}
+
+func TestClient_Send_retry(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var statuses = []string{"r", "*", "1"}
+	var requests int
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "[1]\nmsgid=#000000013\nstatuscode=%s\nAccountPoint=126\n", statuses[requests])
+		requests++
+	})
+	client.Policies = map[Category]*Policy{
+		CategoryOTP: {Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}},
+	}
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{Dstaddr: "0987654321", Smbody: "Code", Category: CategoryOTP},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if resp.Results[0].StatusCode != StatusCarrierAccepted {
+		t.Errorf("Send returned status %v, want %v", resp.Results[0].StatusCode, StatusCarrierAccepted)
+	}
+	if requests != 3 {
+		t.Errorf("Send made %d requests, want %d", requests, 3)
+	}
+
+	// Marketing messages have no retry policy.
+	requests = 0
+	resp, _ = client.Send(context.Background(), MessageParams{
+		Message: Message{Dstaddr: "0987654321", Smbody: "Sale", Category: CategoryMarketing},
+	})
+	if requests != 1 || resp.Results[0].StatusCode != StatusServiceTemporarilyUnavailable {
+		t.Errorf("Send made %d requests and returned %+v", requests, resp.Results[0])
+	}
+}
@@ -0,0 +1,269 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+// Account is a named Mitake account of a Pool.
+type Account struct {
+	Name   string
+	Client *Client
+
+	// Categories restricts the account to messages of these categories, any
+	// category if empty.
+	Categories []Category
+	// Brands restricts the account to sends for these brands, see WithBrand, any
+	// brand if empty.
+	Brands []string
+	// MinBalance moves the account after the others of a route once its last known
+	// AccountPoint is below MinBalance.
+	MinBalance int
+}
+
+// Pool sends through several Mitake accounts. Each message is routed to the
+// accounts that accept its Category and the brand of the context, in order, and
+// fails over to the next account when one returns a status in StatusClassAccount,
+// such as StatusAccountExpired, StatusAccountDisabled or StatusAccountingFailure,
+// when one cannot be connected to, or when its Breaker is open. Other transport
+// errors, such as timeouts, may hide messages the account accepted, so they stop
+// the route instead of sending the messages twice.
+//
+// When some messages could not be sent, the results of the others are returned
+// with the error. Messages without ClientID get one from the ClientIDs of the
+// first account of their route, and keep it through the failovers.
+//
+// The AccountPoint of the responses of a Pool is the total of the last known
+// balances of its accounts.
+//
+// Example usage:
+//
+//	pool := &mitake.Pool{Accounts: []*mitake.Account{
+//		{Name: "brand-a", Client: a, Brands: []string{"a"}, MinBalance: 100},
+//		{Name: "marketing", Client: m, Categories: []mitake.Category{mitake.CategoryMarketing}},
+//		{Name: "default", Client: d},
+//	}}
+//	resp, err := pool.Send(mitake.WithBrand(ctx, "a"), params)
+type Pool struct {
+	Accounts []*Account
+
+	mu       sync.Mutex
+	balances map[string]int // Last known AccountPoint by account name
+}
+
+var _ Sender = (*Pool)(nil)
+
+type brandKey struct{}
+
+// WithBrand returns a copy of ctx carrying the brand a Pool routes the sends by.
+func WithBrand(ctx context.Context, brand string) context.Context {
+	return context.WithValue(ctx, brandKey{}, brand)
+}
+
+// BrandFromContext returns the brand carried by ctx, if any.
+func BrandFromContext(ctx context.Context) string {
+	brand, _ := ctx.Value(brandKey{}).(string)
+	return brand
+}
+
+// Send sends a SMS through the accounts of its route.
+func (p *Pool) Send(ctx context.Context, params MessageParams) (*MessageResponse, error) {
+	params.Message = p.fillClientIDs(ctx, []Message{params.Message})[0]
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return p.send(ctx, []Message{params.Message}, func(c *Client, messages []Message) (*MessageResponse, error) {
+		params.Message = messages[0]
+		return c.Send(ctx, params)
+	})
+}
+
+// SendBatch sends multiple SMS, in a batch per route.
+func (p *Pool) SendBatch(ctx context.Context, params BatchMessagesParams) (*MessageResponse, error) {
+	params.Messages = p.fillClientIDs(ctx, params.Messages)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return p.send(ctx, params.Messages, func(c *Client, messages []Message) (*MessageResponse, error) {
+		params.Messages = messages
+		return c.SendBatch(ctx, params)
+	})
+}
+
+// QueryAccountPoint queries the balance of every account, and returns their total
+// and the balance of each account by name.
+func (p *Pool) QueryAccountPoint(ctx context.Context) (int, map[string]int, error) {
+	balances := make(map[string]int, len(p.Accounts))
+	total := 0
+	for _, a := range p.Accounts {
+		point, err := a.Client.QueryAccountPoint(ctx)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%s: %w", a.Name, err)
+		}
+		p.setBalance(a.Name, point)
+		balances[a.Name] = point
+		total += point
+	}
+	return total, balances, nil
+}
+
+// send sends the messages grouped by route, and returns the response with their
+// results in order. The routes are all sent, and the results of those that failed
+// are left out of the response.
+func (p *Pool) send(ctx context.Context, messages []Message, send func(*Client, []Message) (*MessageResponse, error)) (*MessageResponse, error) {
+	brand := BrandFromContext(ctx)
+	results := make([]*MessageResult, len(messages))
+
+	var (
+		routes  [][]*Account
+		indexes [][]int // Indexes of the messages of each route
+	)
+	for i, message := range messages {
+		route := p.route(message.Category, brand)
+		if len(route) == 0 {
+			return nil, &ParameterError{Reason: fmt.Sprintf("no account for category %q and brand %q", message.Category, brand)}
+		}
+		j := slices.IndexFunc(routes, func(r []*Account) bool { return slices.Equal(r, route) })
+		if j < 0 {
+			routes = append(routes, route)
+			indexes = append(indexes, nil)
+			j = len(routes) - 1
+		}
+		indexes[j] = append(indexes[j], i)
+	}
+
+	var errs []error
+	for j, route := range routes {
+		if err := p.sendRoute(ctx, route, messages, indexes[j], results, send); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	results = slices.DeleteFunc(results, func(r *MessageResult) bool { return r == nil })
+	if len(errs) > 0 && len(results) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &MessageResponse{Results: results, AccountPoint: p.totalBalance()}, errors.Join(errs...)
+}
+
+// sendRoute sends the messages of pending through the accounts of route, in order,
+// and sets their results. The messages whose result is in StatusClassAccount are
+// sent through the next account, and all of them when the account failed.
+func (p *Pool) sendRoute(ctx context.Context, route []*Account, messages []Message, pending []int, results []*MessageResult, send func(*Client, []Message) (*MessageResponse, error)) error {
+	var err error
+	for _, a := range route {
+		if len(pending) == 0 {
+			return nil
+		}
+		batch := make([]Message, len(pending))
+		for k, i := range pending {
+			batch[k] = messages[i]
+		}
+		var resp *MessageResponse
+		if resp, err = send(a.Client, batch); err != nil {
+			err = fmt.Errorf("%s: %w", a.Name, err)
+			if !failover(ctx, err) {
+				return err
+			}
+			continue
+		}
+		// Responses without any accepted message do not report the balance.
+		if slices.ContainsFunc(resp.Results, func(r *MessageResult) bool { return r.Msgid != "" }) {
+			p.setBalance(a.Name, resp.AccountPoint)
+		}
+
+		var retry []int
+		for k, result := range matchResults(batch, resp) {
+			i := pending[k]
+			if result == nil {
+				return &UnexpectedResponseError{Reason: fmt.Sprintf("%s: no result for message %d", a.Name, i)}
+			}
+			result.Account = a.Name
+			results[i] = result
+			if result.StatusCode.Class() == StatusClassAccount {
+				retry = append(retry, i)
+			}
+		}
+		pending = retry
+	}
+	return err
+}
+
+// failover reports whether the messages an account failed to send with err may be
+// sent through the next one, which is only when the request certainly did not
+// reach Mitake: the connection could not be made, or the Breaker is open. Nothing
+// is sent again once ctx is done.
+func failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var oe *net.OpError
+	return errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// fillClientIDs returns the messages with their missing ClientIDs filled in by the
+// ClientIDs of the first account of their route, copying them rather than
+// changing the caller's slice.
+func (p *Pool) fillClientIDs(ctx context.Context, messages []Message) []Message {
+	brand := BrandFromContext(ctx)
+	filled := make([]Message, len(messages))
+	for i, message := range messages {
+		if route := p.route(message.Category, brand); len(route) > 0 {
+			route[0].Client.fillClientID(&message)
+		}
+		filled[i] = message
+	}
+	return filled
+}
+
+// route returns the accounts accepting the category and the brand, those whose
+// balance is below their MinBalance last.
+func (p *Pool) route(category Category, brand string) []*Account {
+	var healthy, low []*Account
+	for _, a := range p.Accounts {
+		if len(a.Categories) > 0 && !slices.Contains(a.Categories, category) {
+			continue
+		}
+		if len(a.Brands) > 0 && !slices.Contains(a.Brands, brand) {
+			continue
+		}
+		if balance, ok := p.balance(a.Name); ok && balance < a.MinBalance {
+			low = append(low, a)
+		} else {
+			healthy = append(healthy, a)
+		}
+	}
+	return append(healthy, low...)
+}
+
+func (p *Pool) balance(name string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	balance, ok := p.balances[name]
+	return balance, ok
+}
+
+func (p *Pool) setBalance(name string, balance int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.balances == nil {
+		p.balances = make(map[string]int)
+	}
+	p.balances[name] = balance
+}
+
+func (p *Pool) totalBalance() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for _, balance := range p.balances {
+		total += balance
+	}
+	return total
+}
@@ -0,0 +1,189 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// poolAccount returns an account whose sends return status for every message and
+// report balance.
+func poolAccount(name, status string, balance int, sent *[]string) (*Account, func()) {
+	client, mux, teardown := setup()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		for _, line := range strings.Split(strings.TrimSuffix(string(body), "\r\n"), "\r\n") {
+			clientID := strings.Split(line, "$$")[0]
+			*sent = append(*sent, name+":"+clientID)
+			_, _ = fmt.Fprintf(w, "[%s]\nmsgid=#%s\nstatuscode=%s\n", clientID, clientID, status)
+		}
+		_, _ = fmt.Fprintf(w, "AccountPoint=%d\n", balance)
+	}
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", handler)
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		clientID := r.FormValue("clientid")
+		*sent = append(*sent, name+":"+clientID)
+		_, _ = fmt.Fprintf(w, "[1]\nmsgid=#%s\nstatuscode=%s\nAccountPoint=%d\n", clientID, status, balance)
+	})
+	return &Account{Name: name, Client: client}, teardown
+}
+
+func TestPool_SendBatch(t *testing.T) {
+	var sent []string
+	disabled, teardown := poolAccount("disabled", "h", 0, &sent)
+	defer teardown()
+	marketing, teardown := poolAccount("marketing", "1", 50, &sent)
+	defer teardown()
+	backup, teardown := poolAccount("backup", "1", 100, &sent)
+	defer teardown()
+	marketing.Categories = []Category{CategoryMarketing}
+
+	pool := &Pool{Accounts: []*Account{disabled, marketing, backup}}
+	resp, err := pool.SendBatch(context.Background(), BatchMessagesParams{Messages: []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Sale", Category: CategoryMarketing},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Code", Category: CategoryOTP},
+	}})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	var accounts []string
+	for _, result := range resp.Results {
+		accounts = append(accounts, result.ClientID+"@"+result.Account)
+	}
+	if want := []string{"0aab@marketing", "1aab@backup"}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("SendBatch sent through %v, want %v", accounts, want)
+	}
+	want := []string{"disabled:0aab", "marketing:0aab", "disabled:1aab", "backup:1aab"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("SendBatch sent %v, want %v", sent, want)
+	}
+	if resp.AccountPoint != 150 {
+		t.Errorf("SendBatch returned AccountPoint %d, want %d", resp.AccountPoint, 150)
+	}
+}
+
+func TestPool_Send_brandAndBalance(t *testing.T) {
+	var sent []string
+	brand, teardown := poolAccount("brand", "1", 10, &sent)
+	defer teardown()
+	other, teardown := poolAccount("other", "1", 100, &sent)
+	defer teardown()
+	brand.Brands = []string{"a"}
+	brand.MinBalance = 20
+
+	pool := &Pool{Accounts: []*Account{brand, other}}
+	send := func(ctx context.Context) string {
+		resp, err := pool.Send(ctx, MessageParams{Message: Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Hello"}})
+		if err != nil {
+			t.Fatalf("Send returned unexpected error: %v", err)
+		}
+		return resp.Results[0].Account
+	}
+
+	if got := send(context.Background()); got != "other" {
+		t.Errorf("Send without brand sent through %v, want %v", got, "other")
+	}
+	if got := send(WithBrand(context.Background(), "a")); got != "brand" {
+		t.Errorf("Send for brand sent through %v, want %v", got, "brand")
+	}
+	// The balance of the brand account is now known to be below its MinBalance.
+	if got := send(WithBrand(context.Background(), "a")); got != "other" {
+		t.Errorf("Send for brand with low balance sent through %v, want %v", got, "other")
+	}
+}
+
+func TestPool_SendBatch_failover(t *testing.T) {
+	var sent []string
+	down, teardown := poolAccount("down", "1", 0, &sent)
+	teardown()
+	open, teardown := poolAccount("open", "1", 0, &sent)
+	defer teardown()
+	open.Client.Breaker = &CircuitBreaker{FailureThreshold: 1}
+	open.Client.Breaker.Failure()
+	marketing, teardown := poolAccount("marketing", "1", 50, &sent)
+	defer teardown()
+	marketing.Categories = []Category{CategoryMarketing}
+
+	pool := &Pool{Accounts: []*Account{down, open, marketing}}
+	resp, err := pool.SendBatch(context.Background(), BatchMessagesParams{Messages: []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Sale", Category: CategoryMarketing},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Code", Category: CategoryOTP},
+	}})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("SendBatch returned error %v, want %v", err, ErrCircuitOpen)
+	}
+	if resp == nil || len(resp.Results) != 1 || resp.Results[0].ClientID != "0aab" || resp.Results[0].Account != "marketing" {
+		t.Fatalf("SendBatch returned %+v, want the result of 0aab through marketing", resp)
+	}
+	if want := []string{"marketing:0aab"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("SendBatch sent %v, want %v", sent, want)
+	}
+}
+
+func TestPool_SendBatch_noFailoverAfterRequest(t *testing.T) {
+	var sent []string
+	client, mux, teardown := setup()
+	defer teardown()
+	// The request reaches the account, which drops the connection without answering.
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack returned unexpected error: %v", err)
+			return
+		}
+		conn.Close()
+	})
+	backup, teardown := poolAccount("backup", "1", 0, &sent)
+	defer teardown()
+
+	pool := &Pool{Accounts: []*Account{{Name: "dropping", Client: client}, backup}}
+	_, err := pool.SendBatch(context.Background(), BatchMessagesParams{Messages: []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Code"},
+	}})
+	if err == nil {
+		t.Error("SendBatch expected an error")
+	}
+	if len(sent) != 0 {
+		t.Errorf("SendBatch sent %v through the backup account, want nothing", sent)
+	}
+}
+
+func TestPool_SendBatch_clientIDs(t *testing.T) {
+	var sent []string
+	disabled, teardown := poolAccount("disabled", "h", 0, &sent)
+	defer teardown()
+	backup, teardown := poolAccount("backup", "1", 100, &sent)
+	defer teardown()
+	disabled.Client.ClientIDs = ClientIDFunc(func(Message) string { return "generated" })
+
+	pool := &Pool{Accounts: []*Account{disabled, backup}}
+	resp, err := pool.SendBatch(context.Background(), BatchMessagesParams{Messages: []Message{
+		{Dstaddr: "0987654321", Smbody: "Hello"},
+	}})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+	if want := []string{"disabled:generated", "backup:generated"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("SendBatch sent %v, want %v", sent, want)
+	}
+	if resp.Results[0].ClientID != "generated" {
+		t.Errorf("SendBatch returned ClientID %v, want %v", resp.Results[0].ClientID, "generated")
+	}
+}
+
+func TestPool_Send_noAccount(t *testing.T) {
+	pool := &Pool{Accounts: []*Account{{Name: "a", Client: NewClient("username", "password", nil), Brands: []string{"a"}}}}
+
+	_, err := pool.Send(context.Background(), MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}})
+	var pe *ParameterError
+	if !errors.As(err, &pe) {
+		t.Errorf("Send returned error %v, want a ParameterError", err)
+	}
+}
@@ -0,0 +1,339 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Errors returned by a Queue.
+var (
+	ErrQueueFull   = errors.New("queue is full")
+	ErrQueueClosed = errors.New("queue is closed")
+)
+
+const (
+	defaultQueueLinger   = time.Second
+	defaultQueueCapacity = 10000
+)
+
+// QueueStore is a durable backend of a Queue. A message is put before it is
+// accepted by the queue, and deleted once its result is known, so that the messages
+// of a crashed process can be sent again with Recover.
+type QueueStore interface {
+	Put(ctx context.Context, message Message) error
+	Delete(ctx context.Context, clientID string) error
+	List(ctx context.Context) ([]Message, error)
+}
+
+// Queue sends messages asynchronously. It groups the queued messages into batches
+// of at most MaxBatch messages, or the messages queued within Linger, and sends the
+// batches from a pool of Workers goroutines.
+//
+// The Queue is started by the first call to Enqueue, TryEnqueue or Recover, and its
+// fields must not be changed after that. Messages without ClientID get one from
+// the ClientIDs of the Sender, when it is a *Client, or a random one, which is used
+// to match the results.
+//
+// Example usage:
+//
+//	queue := &mitake.Queue{Sender: client, Linger: 500 * time.Millisecond}
+//	future, err := queue.TryEnqueue(message)
+//	...
+//	result, err := future.Result(ctx)
+//	...
+//	err = queue.Shutdown(ctx)
+type Queue struct {
+	Sender Sender
+	// Params holds the options of every batch; its Messages are ignored.
+	Params   BatchMessagesParams
+	MaxBatch int           // Maximum messages per batch, defaults to MaxBatchMessages
+	Linger   time.Duration // Maximum time a message waits for a batch to fill up, defaults to 1s
+	Workers  int           // Number of batches sent concurrently, defaults to 4
+	Capacity int           // Number of messages that can be pending, defaults to 10000
+	Store    QueueStore    // Optional durable backend
+
+	// OnResult, when set, is called with the result of every message, or the error
+	// that prevented it from being sent.
+	OnResult func(message Message, result *MessageResult, err error)
+
+	start   sync.Once
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{} // Closed by Shutdown, to wake up the blocked producers
+	slots   chan struct{} // Holds a value for every pending message
+	queue   chan *queuedMessage
+	batches chan []*queuedMessage
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+type queuedMessage struct {
+	Message
+	future *Future
+}
+
+// Future is the pending result of a queued message.
+type Future struct {
+	done   chan struct{}
+	result *MessageResult
+	err    error
+}
+
+// Done returns a channel that is closed when the result is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the result of the message, or for ctx to be done.
+func (f *Future) Result(ctx context.Context) (*MessageResult, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Enqueue queues the message, waiting for space in the queue if it is full.
+func (q *Queue) Enqueue(ctx context.Context, message Message) (*Future, error) {
+	return q.enqueue(ctx, message, true)
+}
+
+// TryEnqueue queues the message, or returns ErrQueueFull without waiting if the
+// queue is full.
+func (q *Queue) TryEnqueue(message Message) (*Future, error) {
+	return q.enqueue(context.Background(), message, false)
+}
+
+// Recover queues again the messages left in the Store by a previous process. Their
+// results are only reported through OnResult. Mitake ignores a message whose
+// ClientID it has already accepted, so a message is not sent twice.
+func (q *Queue) Recover(ctx context.Context) error {
+	if q.Store == nil {
+		return nil
+	}
+	messages, err := q.Store.List(ctx)
+	if err != nil {
+		return err
+	}
+	q.start.Do(q.run)
+	for _, message := range messages {
+		if err := q.push(ctx, &queuedMessage{Message: message, future: newFuture()}, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting messages, and waits until the queued messages have been
+// sent. If ctx is done first, the batches in flight are canceled and ctx.Err() is
+// returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.start.Do(q.run)
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) enqueue(ctx context.Context, message Message, wait bool) (*Future, error) {
+	if c, ok := q.Sender.(*Client); ok {
+		c.fillClientID(&message)
+	}
+	if message.ClientID == "" {
+		message.ClientID = newClientID()
+	}
+	if err := (BatchMessagesParams{Messages: []Message{message}}).Validate(); err != nil {
+		return nil, err
+	}
+	q.start.Do(q.run)
+
+	m := &queuedMessage{Message: message, future: newFuture()}
+	if q.Store != nil {
+		if err := q.Store.Put(ctx, message); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.push(ctx, m, wait); err != nil {
+		if q.Store != nil {
+			_ = q.Store.Delete(context.WithoutCancel(ctx), message.ClientID)
+		}
+		return nil, err
+	}
+	return m.future, nil
+}
+
+func (q *Queue) push(ctx context.Context, m *queuedMessage, wait bool) error {
+	// The slot is taken without holding the lock, so that Shutdown is never
+	// blocked by a producer waiting for space.
+	if !wait {
+		select {
+		case q.slots <- struct{}{}:
+		case <-q.closing:
+			return ErrQueueClosed
+		default:
+			return ErrQueueFull
+		}
+	} else {
+		select {
+		case q.slots <- struct{}{}:
+		case <-q.closing:
+			return ErrQueueClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		<-q.slots
+		return ErrQueueClosed
+	}
+	// The queue has room for every slot, so the send does not block.
+	q.queue <- m
+	return nil
+}
+
+// run starts the goroutines of the queue.
+func (q *Queue) run() {
+	capacity := q.Capacity
+	if capacity <= 0 {
+		capacity = defaultQueueCapacity
+	}
+	workers := q.Workers
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	q.closing = make(chan struct{})
+	q.slots = make(chan struct{}, capacity)
+	q.queue = make(chan *queuedMessage, capacity)
+	q.batches = make(chan []*queuedMessage)
+	q.done = make(chan struct{})
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for batch := range q.batches {
+				q.send(batch)
+			}
+		}()
+	}
+	go func() {
+		q.collect()
+		close(q.batches)
+		wg.Wait()
+		q.cancel()
+		close(q.done)
+	}()
+}
+
+// collect groups the queued messages into batches until the queue is closed. A
+// message whose ClientID is already in the batch starts a new one, since Mitake
+// rejects a request with duplicate ClientIDs.
+func (q *Queue) collect() {
+	size := q.MaxBatch
+	if size <= 0 || size > MaxBatchMessages {
+		size = MaxBatchMessages
+	}
+	linger := q.Linger
+	if linger <= 0 {
+		linger = defaultQueueLinger
+	}
+
+	var (
+		batch     []*queuedMessage
+		clientIDs = make(map[string]bool, size)
+		timer     = time.NewTimer(linger)
+	)
+	timer.Stop()
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			q.batches <- batch
+			batch = nil
+			clear(clientIDs)
+		}
+	}
+	for {
+		select {
+		case m, ok := <-q.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if clientIDs[m.ClientID] {
+				flush()
+			}
+			if len(batch) == 0 {
+				timer.Reset(linger)
+			}
+			batch = append(batch, m)
+			clientIDs[m.ClientID] = true
+			if len(batch) == size {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// send sends a batch and resolves the futures of its messages.
+func (q *Queue) send(batch []*queuedMessage) {
+	messages := make([]Message, len(batch))
+	for i, m := range batch {
+		messages[i] = m.Message
+	}
+	params := q.Params
+	params.Messages = messages
+	resp, err := q.Sender.SendBatch(q.ctx, params)
+	if err != nil {
+		for _, m := range batch {
+			q.resolve(m, nil, err)
+		}
+		return
+	}
+	for i, result := range matchResults(messages, resp) {
+		if result == nil {
+			q.resolve(batch[i], nil, &UnexpectedResponseError{Reason: "no result for ClientID " + batch[i].ClientID})
+			continue
+		}
+		q.resolve(batch[i], result, nil)
+	}
+}
+
+// resolve completes the future of the message. A message that failed to be sent
+// is kept in the Store, to be sent again by Recover.
+func (q *Queue) resolve(m *queuedMessage, result *MessageResult, err error) {
+	if q.Store != nil && err == nil {
+		_ = q.Store.Delete(context.Background(), m.ClientID)
+	}
+	m.future.result, m.future.err = result, err
+	close(m.future.done)
+	<-q.slots
+	if q.OnResult != nil {
+		q.OnResult(m.Message, result, err)
+	}
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
@@ -0,0 +1,253 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryQueueStore is a QueueStore in memory.
+type memoryQueueStore struct {
+	mu       sync.Mutex
+	messages map[string]Message
+}
+
+func (s *memoryQueueStore) Put(ctx context.Context, message Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.messages == nil {
+		s.messages = make(map[string]Message)
+	}
+	s.messages[message.ClientID] = message
+	return nil
+}
+
+func (s *memoryQueueStore) Delete(ctx context.Context, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, clientID)
+	return nil
+}
+
+func (s *memoryQueueStore) List(ctx context.Context) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []Message
+	for _, message := range s.messages {
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func TestQueue(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	queue := &Queue{Sender: client, MaxBatch: 2, Linger: time.Hour, Workers: 1}
+
+	var futures []*Future
+	for _, clientID := range []string{"0aab", "1aab", "2aab"} {
+		future, err := queue.TryEnqueue(Message{ClientID: clientID, Dstaddr: "0987654321", Smbody: "Test"})
+		if err != nil {
+			t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+		}
+		futures = append(futures, future)
+	}
+
+	result, err := futures[0].Result(context.Background())
+	if err != nil {
+		t.Fatalf("Result returned unexpected error: %v", err)
+	}
+	if want := (&MessageResult{ClientID: "0aab", Msgid: "#0aab", StatusCode: StatusCarrierAccepted}); !reflect.DeepEqual(result, want) {
+		t.Errorf("Result returned %+v, want %+v", result, want)
+	}
+
+	// The last message waits for the batch to fill up, until the shutdown.
+	select {
+	case <-futures[2].Done():
+		t.Fatal("Future of a lingering message is done")
+	default:
+	}
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned unexpected error: %v", err)
+	}
+	if result, err := futures[2].Result(context.Background()); err != nil || result.Msgid != "#2aab" {
+		t.Errorf("Result returned %+v, %v after shutdown", result, err)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("Queue sent %d batches, want %d", len(bodies), 2)
+	}
+
+	if _, err := queue.TryEnqueue(Message{ClientID: "3aab", Dstaddr: "0987654321", Smbody: "Test"}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("TryEnqueue returned error %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestQueue_linger(t *testing.T) {
+	sender := &fakeSender{statuses: map[string]StatusCode{"0987654321": StatusCarrierAccepted}}
+	queue := &Queue{Sender: sender, Linger: time.Millisecond, Workers: 1}
+	defer queue.Shutdown(context.Background())
+
+	future, err := queue.TryEnqueue(Message{Dstaddr: "0987654321", Smbody: "Test"})
+	if err != nil {
+		t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := future.Result(ctx)
+	if err != nil {
+		t.Fatalf("Result returned unexpected error: %v", err)
+	}
+	if result.ClientID == "" || result.StatusCode != StatusCarrierAccepted {
+		t.Errorf("Result returned %+v", result)
+	}
+}
+
+func TestQueue_clientIDs(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.ClientIDs = ClientIDFunc(func(Message) string { return "generated" })
+
+	queue := &Queue{Sender: client, Linger: time.Millisecond, Workers: 1}
+	defer queue.Shutdown(context.Background())
+
+	future, err := queue.TryEnqueue(Message{Dstaddr: "0987654321", Smbody: "Test"})
+	if err != nil {
+		t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if result, err := future.Result(ctx); err != nil || result.ClientID != "generated" {
+		t.Errorf("Result returned %+v, %v, want the ClientID of the client", result, err)
+	}
+}
+
+func TestQueue_duplicateClientID(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	queue := &Queue{Sender: client, Linger: time.Hour, Workers: 1}
+
+	var futures []*Future
+	for _, clientID := range []string{"0aab", "1aab", "0aab"} {
+		future, err := queue.TryEnqueue(Message{ClientID: clientID, Dstaddr: "0987654321", Smbody: "Test"})
+		if err != nil {
+			t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+		}
+		futures = append(futures, future)
+	}
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned unexpected error: %v", err)
+	}
+
+	for i, future := range futures {
+		if _, err := future.Result(context.Background()); err != nil {
+			t.Errorf("Result %d returned unexpected error: %v", i, err)
+		}
+	}
+	want := []string{
+		"0aab$$0987654321$$$$$$$$$$Test\r\n1aab$$0987654321$$$$$$$$$$Test\r\n",
+		"0aab$$0987654321$$$$$$$$$$Test\r\n",
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("Queue sent %q, want %q", bodies, want)
+	}
+}
+
+func TestQueue_full(t *testing.T) {
+	sender := &fakeSender{}
+	queue := &Queue{Sender: sender, Linger: time.Hour, Capacity: 1, MaxBatch: MaxBatchMessages}
+	defer queue.Shutdown(context.Background())
+
+	message := Message{Dstaddr: "0987654321", Smbody: "Test"}
+	if _, err := queue.TryEnqueue(message); err != nil {
+		t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+	}
+	if _, err := queue.TryEnqueue(message); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("TryEnqueue returned error %v, want %v", err, ErrQueueFull)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := queue.Enqueue(ctx, message); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Enqueue returned error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueue_Shutdown_blockedProducer(t *testing.T) {
+	queue := &Queue{Sender: &fakeSender{}, Linger: time.Hour, Capacity: 1}
+
+	message := Message{Dstaddr: "0987654321", Smbody: "Test"}
+	if _, err := queue.TryEnqueue(message); err != nil {
+		t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+	}
+	blocked := make(chan error)
+	go func() {
+		_, err := queue.Enqueue(context.Background(), message)
+		blocked <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := queue.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown returned unexpected error: %v", err)
+	}
+	if err := <-blocked; !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue returned error %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestQueue_store(t *testing.T) {
+	store := new(memoryQueueStore)
+	failing := &Queue{Sender: &fakeSender{err: errors.New("unavailable")}, Linger: time.Millisecond, Store: store}
+
+	future, err := failing.TryEnqueue(Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test"})
+	if err != nil {
+		t.Fatalf("TryEnqueue returned unexpected error: %v", err)
+	}
+	if _, err := future.Result(context.Background()); err == nil {
+		t.Fatal("Result did not return error")
+	}
+	_ = failing.Shutdown(context.Background())
+
+	var (
+		mu      sync.Mutex
+		results []*MessageResult
+	)
+	queue := &Queue{
+		Sender: &fakeSender{statuses: map[string]StatusCode{"0987654321": StatusCarrierAccepted}},
+		Linger: time.Millisecond,
+		Store:  store,
+		OnResult: func(message Message, result *MessageResult, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		},
+	}
+	if err := queue.Recover(context.Background()); err != nil {
+		t.Fatalf("Recover returned unexpected error: %v", err)
+	}
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned unexpected error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Msgid != "#0aab" {
+		t.Errorf("OnResult was called with %+v", results)
+	}
+	if messages, _ := store.List(context.Background()); len(messages) != 0 {
+		t.Errorf("Store kept %+v", messages)
+	}
+}
@@ -0,0 +1,82 @@
+package mitake
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours defers the delivery of messages that would reach the recipients during
+// the quiet hours, from Start to End, for example from 21:00 to 08:00. A deferred
+// message gets a Dlvtime at End, and its Vldtime, if any and not a number of
+// seconds, is moved by the same amount. Messages of a transactional Category without Policy are exempt.
+//
+// The deferred delivery time is reported in the DeferredTo field of the result.
+//
+// Example usage:
+//
+//	client.QuietHours = &mitake.QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}
+type QuietHours struct {
+	Start    time.Duration  // Start of the quiet hours, as the time since midnight
+	End      time.Duration  // End of the quiet hours, as the time since midnight
+	Location *time.Location // Time zone of Start and End, defaults to Taipei
+
+	now func() time.Time // For testing
+}
+
+// Next returns the earliest time at or after t that is outside the quiet hours.
+func (q *QuietHours) Next(t time.Time) time.Time {
+	if q.Start == q.End {
+		return t
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = Taipei
+	}
+	t = t.In(loc)
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+
+	switch {
+	case q.Start < q.End && offset >= q.Start && offset < q.End:
+		return midnight.Add(q.End)
+	case q.Start > q.End && offset >= q.Start:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, loc).Add(q.End)
+	case q.Start > q.End && offset < q.End:
+		return midnight.Add(q.End)
+	}
+	return t
+}
+
+// apply defers the message if it would be delivered during the quiet hours.
+func (q *QuietHours) apply(p *preparedMessage) error {
+	at := time.Now()
+	if q.now != nil {
+		at = q.now()
+	}
+	if p.Dlvtime != "" {
+		t, err := ParseTime(p.Dlvtime)
+		if err != nil {
+			return &ParameterError{Reason: fmt.Sprintf("[%s] invalid Dlvtime %q", p.ClientID, p.Dlvtime)}
+		}
+		at = t
+	}
+
+	next := q.Next(at)
+	if !next.After(at) {
+		return nil
+	}
+	if _, ok := vldtimeSeconds(p.Vldtime); !ok && p.Vldtime != "" {
+		t, err := ParseTime(p.Vldtime)
+		if err != nil {
+			return &ParameterError{Reason: fmt.Sprintf("[%s] invalid Vldtime %q", p.ClientID, p.Vldtime)}
+		}
+		p.Vldtime = FormatTime(t.Add(next.Sub(at)))
+	}
+	p.Dlvtime = FormatTime(next)
+	deferredTo := p.Dlvtime
+	p.annotations = append(p.annotations, func(result *MessageResult) {
+		result.DeferredTo = deferredTo
+	})
+	return nil
+}
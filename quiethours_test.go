@@ -0,0 +1,109 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Next(t *testing.T) {
+	tests := []struct {
+		q    QuietHours
+		t    string
+		want string
+	}{
+		{QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}, "20170101120000", "20170101120000"},
+		{QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}, "20170101220000", "20170102080000"},
+		{QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}, "20170101030000", "20170101080000"},
+		{QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}, "20170101080000", "20170101080000"},
+		{QuietHours{Start: 12 * time.Hour, End: 14 * time.Hour}, "20170101123000", "20170101140000"},
+		{QuietHours{Start: 12 * time.Hour, End: 14 * time.Hour}, "20170101143000", "20170101143000"},
+		{QuietHours{}, "20170101030000", "20170101030000"},
+	}
+
+	for _, tt := range tests {
+		at, _ := ParseTime(tt.t)
+		if got := FormatTime(tt.q.Next(at)); got != tt.want {
+			t.Errorf("Next(%v) with %v-%v returned %v, want %v", tt.t, tt.q.Start, tt.q.End, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHours_Next_location(t *testing.T) {
+	q := &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour, Location: time.UTC}
+
+	// 06:00 in Taipei is 22:00 UTC, deferred to 08:00 UTC, which is 16:00 in Taipei.
+	at, _ := ParseTime("20170102060000")
+	if got, want := FormatTime(q.Next(at)), "20170102160000"; got != want {
+		t.Errorf("Next returned %v, want %v", got, want)
+	}
+}
+
+func TestClient_Send_quietHours(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		testFormData(t, r, map[string][]string{
+			"username":     {"username"},
+			"password":     {"password"},
+			"dstaddr":      {"0987654321"},
+			"smbody":       {"Sale"},
+			"dlvtime":      {"20170102080000"},
+			"vldtime":      {"20170102100000"},
+			"smsPointFlag": {"1"},
+		})
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=0\nAccountPoint=126\n")
+	})
+	now, _ := ParseTime("20170101230000")
+	client.QuietHours = &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour, now: func() time.Time { return now }}
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{Dstaddr: "0987654321", Smbody: "Sale", Vldtime: "20170102010000"},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if got, want := resp.Results[0].DeferredTo, "20170102080000"; got != want {
+		t.Errorf("Send returned DeferredTo %v, want %v", got, want)
+	}
+}
+
+func TestQuietHours_apply_vldtimeSeconds(t *testing.T) {
+	now, _ := ParseTime("20170101230000")
+	q := &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour, now: func() time.Time { return now }}
+
+	p := &preparedMessage{Message: Message{Dstaddr: "0987654321", Smbody: "Sale", Vldtime: "3600"}}
+	if err := q.apply(p); err != nil {
+		t.Fatalf("apply returned unexpected error: %v", err)
+	}
+	if got, want := p.Dlvtime+"/"+p.Vldtime, "20170102080000/3600"; got != want {
+		t.Errorf("apply set %v, want %v", got, want)
+	}
+}
+
+func TestClient_Send_quietHoursTransactional(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		if dlvtime := r.PostFormValue("dlvtime"); dlvtime != "" {
+			t.Errorf("Send deferred a transactional message to %v", dlvtime)
+		}
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000013\nstatuscode=1\nAccountPoint=126\n")
+	})
+	now, _ := ParseTime("20170101230000")
+	client.QuietHours = &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour, now: func() time.Time { return now }}
+
+	resp, err := client.Send(context.Background(), MessageParams{
+		Message: Message{Dstaddr: "0987654321", Smbody: "Your code is 1234", Category: CategoryOTP},
+	})
+	if err != nil {
+		t.Fatalf("Send returned unexpected error: %v", err)
+	}
+	if resp.Results[0].DeferredTo != "" {
+		t.Errorf("Send returned DeferredTo %v", resp.Results[0].DeferredTo)
+	}
+}
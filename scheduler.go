@@ -0,0 +1,212 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors returned by a Scheduler.
+var (
+	ErrAlreadySent      = errors.New("message already sent")
+	ErrScheduleNotFound = errors.New("scheduled message not found")
+)
+
+// RescheduleError is returned by Reschedule when a canceled message was sent
+// again, but Mitake did not accept it.
+type RescheduleError struct {
+	Msgid  string         // The msgid of the canceled message
+	Result *MessageResult // The result of the message sent again
+}
+
+func (e *RescheduleError) Error() string {
+	return fmt.Sprintf("reschedule %s: canceled but not reserved again: %s (%s)", e.Msgid, string(e.Result.StatusCode), e.Result.StatusCode)
+}
+
+// ScheduledMessage is a message sent with a Dlvtime, as recorded by a Scheduler.
+type ScheduledMessage struct {
+	Msgid    string
+	Dlvtime  string // Scheduled delivery time, format: YYYYMMDDHHMMSS
+	ObjectID string
+	Params   MessageParams // The parameters the message was sent with
+}
+
+// ScheduleStore holds the scheduled messages of a Scheduler by msgid. It must be
+// safe for concurrent use.
+type ScheduleStore interface {
+	Save(ctx context.Context, m *ScheduledMessage) error
+	// Load returns the scheduled message of msgid, or ErrScheduleNotFound.
+	Load(ctx context.Context, msgid string) (*ScheduledMessage, error)
+	Delete(ctx context.Context, msgid string) error
+}
+
+// Scheduler sends scheduled messages and records them, so that they can be moved
+// to another time. Mitake cannot reschedule a message, so Reschedule cancels it
+// and sends it again.
+//
+// Example usage:
+//
+//	scheduler := &mitake.Scheduler{Client: client, Store: mitake.NewMemoryScheduleStore()}
+//	result, err := scheduler.Schedule(ctx, params)
+//	...
+//	result, err = scheduler.Reschedule(ctx, result.Msgid, time.Now().Add(time.Hour))
+//	if errors.Is(err, mitake.ErrAlreadySent) { ... }
+type Scheduler struct {
+	Client *Client
+	Store  ScheduleStore
+}
+
+// Schedule sends a message with a Dlvtime and records it. If the QuietHours of
+// the client defer the message, it is recorded with the times it was sent with.
+func (s *Scheduler) Schedule(ctx context.Context, params MessageParams) (*MessageResult, error) {
+	if params.Dlvtime == "" {
+		return nil, &ParameterError{Reason: "empty Dlvtime"}
+	}
+	resp, err := s.Client.Send(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, &UnexpectedResponseError{Reason: "no message result"}
+	}
+	result := resp.Results[0]
+	if result.StatusCode != StatusReservationForDelivery {
+		return result, nil
+	}
+	if result.DeferredTo != "" {
+		at, err := ParseTime(result.DeferredTo)
+		if err != nil {
+			return result, &UnexpectedResponseError{Reason: fmt.Sprintf("invalid DeferredTo %q", result.DeferredTo)}
+		}
+		if err := moveSchedule(&params, at); err != nil {
+			return result, err
+		}
+	}
+	return result, s.Record(ctx, result.Msgid, params)
+}
+
+// moveSchedule moves the Dlvtime of params to at, and its Vldtime, unless it is a
+// number of seconds, by the same amount.
+func moveSchedule(params *MessageParams, at time.Time) error {
+	if _, ok := vldtimeSeconds(params.Vldtime); !ok && params.Vldtime != "" {
+		from, err := ParseTime(params.Dlvtime)
+		if err != nil {
+			return &ParameterError{Reason: fmt.Sprintf("invalid Dlvtime %q", params.Dlvtime)}
+		}
+		vldtime, err := ParseTime(params.Vldtime)
+		if err != nil {
+			return &ParameterError{Reason: fmt.Sprintf("invalid Vldtime %q", params.Vldtime)}
+		}
+		params.Vldtime = FormatTime(vldtime.Add(at.Sub(from)))
+	}
+	params.Dlvtime = FormatTime(at)
+	return nil
+}
+
+// Record records a message scheduled by other means, for example with SendBatch.
+func (s *Scheduler) Record(ctx context.Context, msgid string, params MessageParams) error {
+	return s.Store.Save(ctx, &ScheduledMessage{
+		Msgid:    msgid,
+		Dlvtime:  params.Dlvtime,
+		ObjectID: params.ObjectID,
+		Params:   params,
+	})
+}
+
+// Reschedule moves a scheduled message to at. It cancels the message and sends it
+// again, with its Vldtime moved by the same amount, and returns the result of the
+// new message, which has a new msgid. The message is sent again without its
+// ClientID, which Mitake would reject as a duplicate.
+//
+// If the message left the reservation queue before it could be canceled,
+// Reschedule returns ErrAlreadySent. If the message was canceled but could not be
+// sent again, it stays recorded, and Reschedule can be retried. The same holds
+// when Mitake rejected the new message: Reschedule then returns its result with a
+// *RescheduleError. If Mitake accepted the new message for delivery right away,
+// for example because at has passed, the record is deleted and the result is
+// returned without error.
+func (s *Scheduler) Reschedule(ctx context.Context, msgid string, at time.Time) (*MessageResult, error) {
+	m, err := s.Store.Load(ctx, msgid)
+	if err != nil {
+		return nil, err
+	}
+
+	canceled, err := s.Client.CancelScheduledMessages(ctx, []string{msgid})
+	if err != nil {
+		return nil, err
+	}
+	var status StatusCode
+	for _, c := range canceled {
+		if c.Msgid == msgid {
+			status = c.StatusCode
+		}
+	}
+	switch {
+	case status == StatusReservationCanceled:
+	case status.IsSent():
+		if err := s.Store.Delete(ctx, msgid); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reschedule %s: %w", msgid, ErrAlreadySent)
+	case status == StatusNoDataFound:
+		return nil, fmt.Errorf("reschedule %s: %w", msgid, ErrScheduleNotFound)
+	default:
+		return nil, &UnexpectedResponseError{Reason: fmt.Sprintf("cancel %s returned status %q", msgid, string(status))}
+	}
+
+	params := m.Params
+	params.ClientID = ""
+	params.Dlvtime = m.Dlvtime
+	if err := moveSchedule(&params, at); err != nil {
+		return nil, err
+	}
+
+	result, err := s.Schedule(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("reschedule %s: canceled but not sent again: %w", msgid, err)
+	}
+	if result.StatusCode.Class() != StatusClassSuccess {
+		return result, &RescheduleError{Msgid: msgid, Result: result}
+	}
+	return result, s.Store.Delete(ctx, msgid)
+}
+
+// MemoryScheduleStore is a ScheduleStore in memory.
+type MemoryScheduleStore struct {
+	mu       sync.RWMutex
+	messages map[string]ScheduledMessage
+}
+
+// NewMemoryScheduleStore returns an empty MemoryScheduleStore.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{messages: make(map[string]ScheduledMessage)}
+}
+
+// Save implements ScheduleStore.
+func (s *MemoryScheduleStore) Save(_ context.Context, m *ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[m.Msgid] = *m
+	return nil
+}
+
+// Load implements ScheduleStore.
+func (s *MemoryScheduleStore) Load(_ context.Context, msgid string) (*ScheduledMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.messages[msgid]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return &m, nil
+}
+
+// Delete implements ScheduleStore.
+func (s *MemoryScheduleStore) Delete(_ context.Context, msgid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, msgid)
+	return nil
+}
@@ -0,0 +1,190 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Reschedule(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var sent []string
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		if clientID := r.PostFormValue("clientid"); len(sent) > 0 && clientID != "" {
+			t.Errorf("Reschedule sent ClientID %v again", clientID)
+		}
+		sent = append(sent, r.PostFormValue("dlvtime")+"/"+r.PostFormValue("vldtime"))
+		_, _ = fmt.Fprintf(w, "[1]\nmsgid=#00000000%d\nstatuscode=0\nAccountPoint=126\n", len(sent))
+	})
+	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		if got := r.URL.Query().Get("msgid"); got != "#000000001" {
+			t.Errorf("Reschedule canceled msgid %v, want %v", got, "#000000001")
+		}
+		_, _ = fmt.Fprint(w, "#000000001=9\n")
+	})
+
+	scheduler := &Scheduler{Client: client, Store: NewMemoryScheduleStore()}
+	result, err := scheduler.Schedule(context.Background(), MessageParams{Message: Message{
+		ClientID: "0aab",
+		Dstaddr:  "0987654321",
+		Smbody:   "Sale",
+		Dlvtime:  "20170101100000",
+		Vldtime:  "20170101120000",
+	}})
+	if err != nil {
+		t.Fatalf("Schedule returned unexpected error: %v", err)
+	}
+
+	at, _ := ParseTime("20170102100000")
+	result, err = scheduler.Reschedule(context.Background(), result.Msgid, at)
+	if err != nil {
+		t.Fatalf("Reschedule returned unexpected error: %v", err)
+	}
+	if result.Msgid != "#000000002" {
+		t.Errorf("Reschedule returned msgid %v, want %v", result.Msgid, "#000000002")
+	}
+	if want := "20170102100000/20170102120000"; sent[1] != want {
+		t.Errorf("Reschedule sent %v, want %v", sent[1], want)
+	}
+	if _, err := scheduler.Store.Load(context.Background(), "#000000001"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Reschedule kept the canceled message")
+	}
+	if _, err := scheduler.Store.Load(context.Background(), "#000000002"); err != nil {
+		t.Errorf("Reschedule did not record the new message: %v", err)
+	}
+}
+
+func TestScheduler_Reschedule_alreadySent(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Reschedule sent a message that already went out")
+	})
+	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "#000000001=4\n")
+	})
+
+	scheduler := &Scheduler{Client: client, Store: NewMemoryScheduleStore()}
+	_ = scheduler.Record(context.Background(), "#000000001", MessageParams{Message: Message{
+		Dstaddr: "0987654321",
+		Smbody:  "Sale",
+		Dlvtime: "20170101100000",
+	}})
+
+	_, err := scheduler.Reschedule(context.Background(), "#000000001", time.Now())
+	if !errors.Is(err, ErrAlreadySent) {
+		t.Errorf("Reschedule returned error %v, want %v", err, ErrAlreadySent)
+	}
+	if _, err := scheduler.Store.Load(context.Background(), "#000000001"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Reschedule kept the sent message")
+	}
+}
+
+func TestScheduler_Reschedule_notRecorded(t *testing.T) {
+	scheduler := &Scheduler{Client: NewClient("username", "password", nil), Store: NewMemoryScheduleStore()}
+
+	_, err := scheduler.Reschedule(context.Background(), "#000000001", time.Now())
+	if !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Reschedule returned error %v, want %v", err, ErrScheduleNotFound)
+	}
+}
+
+func TestScheduler_Reschedule_rejected(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[1]\nstatuscode=e\n")
+	})
+	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "#000000001=9\n")
+	})
+
+	scheduler := &Scheduler{Client: client, Store: NewMemoryScheduleStore()}
+	_ = scheduler.Record(context.Background(), "#000000001", MessageParams{Message: Message{
+		Dstaddr: "0987654321",
+		Smbody:  "Sale",
+		Dlvtime: "20170101100000",
+	}})
+
+	result, err := scheduler.Reschedule(context.Background(), "#000000001", time.Date(2017, 1, 1, 12, 0, 0, 0, Taipei))
+	var rescheduleErr *RescheduleError
+	if !errors.As(err, &rescheduleErr) {
+		t.Fatalf("Reschedule returned error %v, want a RescheduleError", err)
+	}
+	if result == nil || rescheduleErr.Result != result || result.StatusCode != StatusCode("e") {
+		t.Errorf("Reschedule returned result %+v, want the rejected result", result)
+	}
+	if _, err := scheduler.Store.Load(context.Background(), "#000000001"); err != nil {
+		t.Errorf("Reschedule did not keep the canceled message: %v", err)
+	}
+}
+
+func TestScheduler_Schedule_quietHours(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000001\nstatuscode=0\nAccountPoint=126\n")
+	})
+	client.QuietHours = &QuietHours{Start: 21 * time.Hour, End: 8 * time.Hour}
+
+	scheduler := &Scheduler{Client: client, Store: NewMemoryScheduleStore()}
+	_, err := scheduler.Schedule(context.Background(), MessageParams{Message: Message{
+		Dstaddr: "0987654321",
+		Smbody:  "Sale",
+		Dlvtime: "20170101220000",
+		Vldtime: "20170101230000",
+	}})
+	if err != nil {
+		t.Fatalf("Schedule returned unexpected error: %v", err)
+	}
+
+	m, err := scheduler.Store.Load(context.Background(), "#000000001")
+	if err != nil {
+		t.Fatalf("Schedule did not record the message: %v", err)
+	}
+	if got, want := m.Params.Dlvtime+"/"+m.Params.Vldtime, "20170102080000/20170102090000"; got != want {
+		t.Errorf("Schedule recorded %v, want %v", got, want)
+	}
+	if m.Dlvtime != "20170102080000" {
+		t.Errorf("Schedule recorded Dlvtime %v, want %v", m.Dlvtime, "20170102080000")
+	}
+}
+
+func TestScheduler_Reschedule_sentNow(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmSend", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "[1]\nmsgid=#000000002\nstatuscode=1\nAccountPoint=126\n")
+	})
+	mux.HandleFunc("/b2c/mtk/SmCancel", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "#000000001=9\n")
+	})
+
+	scheduler := &Scheduler{Client: client, Store: NewMemoryScheduleStore()}
+	_ = scheduler.Record(context.Background(), "#000000001", MessageParams{Message: Message{
+		Dstaddr: "0987654321",
+		Smbody:  "Sale",
+		Dlvtime: "20170101100000",
+	}})
+
+	result, err := scheduler.Reschedule(context.Background(), "#000000001", time.Date(2017, 1, 1, 12, 0, 0, 0, Taipei))
+	if err != nil {
+		t.Fatalf("Reschedule returned unexpected error: %v", err)
+	}
+	if result.StatusCode != StatusCarrierAccepted {
+		t.Errorf("Reschedule returned status %v, want %v", result.StatusCode, StatusCarrierAccepted)
+	}
+	if _, err := scheduler.Store.Load(context.Background(), "#000000001"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Reschedule kept the canceled message")
+	}
+}
@@ -0,0 +1,82 @@
+package mitake
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Segment lengths of a single and a concatenated SMS, in characters of the encoding.
+const (
+	gsm7SingleLength = 160
+	gsm7PartLength   = 153
+	ucs2SingleLength = 70
+	ucs2PartLength   = 67
+)
+
+const (
+	gsm7Basic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "\f^{}\\[~]|€"
+)
+
+// Segments describes how a message body is split into SMS segments.
+type Segments struct {
+	Count  int  // Number of segments, zero for an empty body
+	Length int  // Length of the body in characters of the encoding
+	UCS2   bool // Whether the body needs the UCS-2 encoding instead of the GSM 7-bit alphabet
+}
+
+// PerSegment returns the number of characters that fit in each segment.
+func (s Segments) PerSegment() int {
+	switch {
+	case s.UCS2 && s.Count > 1:
+		return ucs2PartLength
+	case s.UCS2:
+		return ucs2SingleLength
+	case s.Count > 1:
+		return gsm7PartLength
+	default:
+		return gsm7SingleLength
+	}
+}
+
+// CountSegments returns the segments needed to send body. Characters outside the
+// GSM 7-bit alphabet, such as Chinese characters, force the UCS-2 encoding.
+func CountSegments(body string) Segments {
+	var gsm7, ucs2 int
+	isUCS2 := false
+	for _, r := range body {
+		ucs2 += utf16.RuneLen(r)
+		if n := gsm7Length(r); n > 0 {
+			gsm7 += n
+		} else {
+			isUCS2 = true
+		}
+	}
+
+	s := Segments{Length: gsm7, UCS2: isUCS2}
+	single, part := gsm7SingleLength, gsm7PartLength
+	if isUCS2 {
+		s.Length = ucs2
+		single, part = ucs2SingleLength, ucs2PartLength
+	}
+	switch {
+	case s.Length == 0:
+	case s.Length <= single:
+		s.Count = 1
+	default:
+		s.Count = (s.Length + part - 1) / part
+	}
+	return s
+}
+
+// gsm7Length returns the length of r in the GSM 7-bit alphabet, or zero if r is not
+// in the alphabet.
+func gsm7Length(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7Basic, r):
+		return 1
+	case strings.ContainsRune(gsm7Extension, r):
+		return 2
+	}
+	return 0
+}
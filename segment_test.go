@@ -0,0 +1,52 @@
+package mitake
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCountSegments(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected Segments
+	}{
+		{
+			body:     "",
+			expected: Segments{},
+		},
+		{
+			body:     "Hello",
+			expected: Segments{Count: 1, Length: 5},
+		},
+		{
+			body:     "{Hello}",
+			expected: Segments{Count: 1, Length: 9},
+		},
+		{
+			body:     strings.Repeat("a", 160),
+			expected: Segments{Count: 1, Length: 160},
+		},
+		{
+			body:     strings.Repeat("a", 161),
+			expected: Segments{Count: 2, Length: 161},
+		},
+		{
+			body:     "Hello, 世界",
+			expected: Segments{Count: 1, Length: 9, UCS2: true},
+		},
+		{
+			body:     strings.Repeat("世", 71),
+			expected: Segments{Count: 2, Length: 71, UCS2: true},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case=%d", i), func(t *testing.T) {
+			actual := CountSegments(tc.body)
+
+			if actual != tc.expected {
+				t.Errorf("CountSegments returned %+v, want %+v", actual, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Sender sends SMS. It is satisfied by *Client, and can be implemented by other
+// providers to be used with a FailoverSender.
+type Sender interface {
+	Send(ctx context.Context, params MessageParams) (*MessageResponse, error)
+	SendBatch(ctx context.Context, params BatchMessagesParams) (*MessageResponse, error)
+}
+
+var _ Sender = (*Client)(nil)
+
+// Provider is a named Sender of a FailoverSender.
+type Provider struct {
+	Name   string
+	Sender Sender
+	// Breaker tracks the health of the provider. A provider whose circuit is open is
+	// skipped. If nil, a CircuitBreaker with the default settings is used.
+	Breaker *CircuitBreaker
+}
+
+// SendResult is the provider-neutral result of a message sent by a FailoverSender.
+type SendResult struct {
+	Provider  string     // Name of the provider that sent, or last tried to send, the message
+	ClientID  string     // The ClientID of the message
+	MessageID string     // The ID the provider assigned to the message
+	Accepted  bool       // Whether the provider accepted the message
+	Status    StatusCode // The status the provider returned
+}
+
+// FailoverSender sends through the first healthy provider, and fails over to the
+// next one when a provider returns an error or reports that its service is
+// unavailable. In a batch, only the messages that failed are sent again.
+//
+// Once ctx is done, no provider is tried any more: the error of ctx is returned,
+// with the results of the providers that responded, if any, and it does not count
+// as a failure of the provider.
+//
+// Example usage:
+//
+//	sender := &mitake.FailoverSender{
+//		Providers: []*mitake.Provider{
+//			{Name: "mitake", Sender: client},
+//			{Name: "backup", Sender: backup},
+//		},
+//	}
+//	results, err := sender.Send(ctx, params)
+type FailoverSender struct {
+	Providers []*Provider
+	// ShouldFailover reports whether a message must be sent through the next
+	// provider. It defaults to a status in StatusClassUnavailable.
+	ShouldFailover func(result *MessageResult) bool
+
+	mu sync.Mutex
+}
+
+// Send sends a SMS through the first healthy provider.
+func (f *FailoverSender) Send(ctx context.Context, params MessageParams) ([]*SendResult, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return f.send(ctx, []Message{params.Message}, func(ctx context.Context, s Sender, messages []Message) (*MessageResponse, error) {
+		params.Message = messages[0]
+		return s.Send(ctx, params)
+	})
+}
+
+// SendBatch sends multiple SMS through the first healthy provider.
+func (f *FailoverSender) SendBatch(ctx context.Context, params BatchMessagesParams) ([]*SendResult, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return f.send(ctx, params.Messages, func(ctx context.Context, s Sender, messages []Message) (*MessageResponse, error) {
+		params.Messages = messages
+		return s.SendBatch(ctx, params)
+	})
+}
+
+type sendFunc func(ctx context.Context, s Sender, messages []Message) (*MessageResponse, error)
+
+func (f *FailoverSender) send(ctx context.Context, messages []Message, send sendFunc) ([]*SendResult, error) {
+	results := make([]*SendResult, len(messages))
+	pending := make([]int, len(messages)) // Indexes of the messages still to send
+	for i := range messages {
+		pending[i] = i
+	}
+
+	var (
+		lastErr   error
+		responded bool
+	)
+	for _, p := range f.Providers {
+		if len(pending) == 0 || ctx.Err() != nil {
+			break
+		}
+		breaker := f.breaker(p)
+		if err := breaker.Allow(); err != nil {
+			lastErr = fmt.Errorf("%s: %w", p.Name, err)
+			continue
+		}
+
+		batch := make([]Message, len(pending))
+		for i, index := range pending {
+			batch[i] = messages[index]
+		}
+		resp, err := send(ctx, p.Sender, batch)
+		if err != nil {
+			var pe *ParameterError
+			if errors.As(err, &pe) {
+				breaker.Success()
+				return nil, err
+			}
+			if ctx.Err() != nil {
+				breaker.release()
+				break
+			}
+			breaker.Failure()
+			lastErr = fmt.Errorf("%s: %w", p.Name, err)
+			continue
+		}
+
+		responded = true
+		failed := false
+		var retry []int
+		for i, result := range matchResults(batch, resp) {
+			index := pending[i]
+			results[index] = &SendResult{Provider: p.Name, ClientID: messages[index].ClientID}
+			if result == nil {
+				retry = append(retry, index)
+				failed = true
+				continue
+			}
+			results[index].MessageID = result.Msgid
+			results[index].Status = result.StatusCode
+			if f.shouldFailover(result) {
+				retry = append(retry, index)
+				failed = true
+				continue
+			}
+			results[index].Accepted = result.StatusCode.Class() == StatusClassSuccess
+		}
+		if failed {
+			breaker.Failure()
+		} else {
+			breaker.Success()
+		}
+		pending = retry
+	}
+
+	if err := ctx.Err(); err != nil && len(pending) > 0 {
+		if !responded {
+			return nil, err
+		}
+		return results, err
+	}
+	if !responded {
+		if lastErr == nil {
+			lastErr = errors.New("no provider")
+		}
+		return nil, fmt.Errorf("all providers failed: %w", lastErr)
+	}
+	return results, nil
+}
+
+func (f *FailoverSender) shouldFailover(result *MessageResult) bool {
+	if f.ShouldFailover != nil {
+		return f.ShouldFailover(result)
+	}
+	return result.StatusCode.Class() == StatusClassUnavailable
+}
+
+func (f *FailoverSender) breaker(p *Provider) *CircuitBreaker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p.Breaker == nil {
+		p.Breaker = new(CircuitBreaker)
+	}
+	return p.Breaker
+}
+
+// matchResults returns the result of each message, matched by ClientID when the
+// messages have one, or else by position. A message without result gets nil.
+func matchResults(messages []Message, resp *MessageResponse) []*MessageResult {
+	byClientID := make(map[string]*MessageResult, len(resp.Results))
+	for _, result := range resp.Results {
+		if result.ClientID != "" {
+			byClientID[result.ClientID] = result
+		}
+	}
+	matched := make([]*MessageResult, len(messages))
+	for i, message := range messages {
+		if result, ok := byClientID[message.ClientID]; ok && message.ClientID != "" {
+			matched[i] = result
+		} else if i < len(resp.Results) && len(resp.Results) == len(messages) {
+			matched[i] = resp.Results[i]
+		}
+	}
+	return matched
+}
@@ -0,0 +1,144 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeSender returns the status of each message from statuses by Dstaddr, or err.
+type fakeSender struct {
+	statuses map[string]StatusCode
+	err      error
+	sent     [][]Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, params MessageParams) (*MessageResponse, error) {
+	return s.send([]Message{params.Message})
+}
+
+func (s *fakeSender) SendBatch(ctx context.Context, params BatchMessagesParams) (*MessageResponse, error) {
+	return s.send(params.Messages)
+}
+
+func (s *fakeSender) send(messages []Message) (*MessageResponse, error) {
+	s.sent = append(s.sent, messages)
+	if s.err != nil {
+		return nil, s.err
+	}
+	resp := new(MessageResponse)
+	for _, message := range messages {
+		resp.Results = append(resp.Results, &MessageResult{
+			ClientID:   message.ClientID,
+			Msgid:      "#" + message.ClientID,
+			StatusCode: s.statuses[message.Dstaddr],
+		})
+	}
+	return resp, nil
+}
+
+func TestFailoverSender_SendBatch(t *testing.T) {
+	primary := &fakeSender{statuses: map[string]StatusCode{
+		"0987654321": StatusCarrierAccepted,
+		"0987654322": StatusSMSTemporarilyUnavailable,
+	}}
+	backup := &fakeSender{statuses: map[string]StatusCode{
+		"0987654322": StatusCarrierAccepted,
+	}}
+	sender := &FailoverSender{Providers: []*Provider{
+		{Name: "primary", Sender: primary},
+		{Name: "backup", Sender: backup},
+	}}
+
+	results, err := sender.SendBatch(context.Background(), BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+			{ClientID: "1aab", Dstaddr: "0987654322", Smbody: "Test2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	want := []*SendResult{
+		{Provider: "primary", ClientID: "0aab", MessageID: "#0aab", Accepted: true, Status: StatusCarrierAccepted},
+		{Provider: "backup", ClientID: "1aab", MessageID: "#1aab", Accepted: true, Status: StatusCarrierAccepted},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("SendBatch returned %+v, want %+v", results, want)
+	}
+	if len(backup.sent) != 1 || len(backup.sent[0]) != 1 {
+		t.Errorf("SendBatch sent %v through the backup, want only the failed message", backup.sent)
+	}
+}
+
+func TestFailoverSender_Send_circuitOpen(t *testing.T) {
+	primary := &fakeSender{err: errors.New("connection refused")}
+	backup := &fakeSender{statuses: map[string]StatusCode{"0987654321": StatusCarrierAccepted}}
+	sender := &FailoverSender{Providers: []*Provider{
+		{Name: "primary", Sender: primary, Breaker: &CircuitBreaker{FailureThreshold: 1}},
+		{Name: "backup", Sender: backup},
+	}}
+	params := MessageParams{Message: Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Hello"}}
+
+	for i := 0; i < 2; i++ {
+		results, err := sender.Send(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Send returned unexpected error: %v", err)
+		}
+		if got := results[0].Provider; got != "backup" {
+			t.Errorf("Send sent through %v, want backup", got)
+		}
+	}
+	if len(primary.sent) != 1 {
+		t.Errorf("Send tried the primary %d times, want %d", len(primary.sent), 1)
+	}
+}
+
+func TestFailoverSender_Send_allFailed(t *testing.T) {
+	want := errors.New("connection refused")
+	sender := &FailoverSender{Providers: []*Provider{
+		{Name: "primary", Sender: &fakeSender{err: want}},
+	}}
+
+	_, err := sender.Send(context.Background(), MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}})
+	if !errors.Is(err, want) {
+		t.Errorf("Send returned error %v, want %v", err, want)
+	}
+}
+
+// cancelingSender cancels the context of the send, as a caller giving up would.
+type cancelingSender struct {
+	fakeSender
+	cancel context.CancelFunc
+}
+
+func (s *cancelingSender) Send(ctx context.Context, params MessageParams) (*MessageResponse, error) {
+	s.cancel()
+	s.sent = append(s.sent, []Message{params.Message})
+	return nil, ctx.Err()
+}
+
+func TestFailoverSender_Send_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary := &cancelingSender{cancel: cancel}
+	backup := &fakeSender{statuses: map[string]StatusCode{"0987654321": StatusCarrierAccepted}}
+	breaker := &CircuitBreaker{FailureThreshold: 1}
+	sender := &FailoverSender{Providers: []*Provider{
+		{Name: "primary", Sender: primary, Breaker: breaker},
+		{Name: "backup", Sender: backup},
+	}}
+
+	_, err := sender.Send(ctx, MessageParams{Message: Message{Dstaddr: "0987654321", Smbody: "Hello"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Send returned error %v, want %v", err, context.Canceled)
+	}
+	if len(backup.sent) != 0 {
+		t.Errorf("Send tried the backup %d times after the cancellation, want none", len(backup.sent))
+	}
+	if got := breaker.State(); got != CircuitClosed {
+		t.Errorf("Send left the breaker %v, want %v", got, CircuitClosed)
+	}
+}
@@ -0,0 +1,280 @@
+package mitake
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+)
+
+// MaxBatchMessages is the maximum number of messages Mitake accepts in a single
+// bulk send request.
+const MaxBatchMessages = 500
+
+// SendBatchFrom sends the messages produced by the iterator in chunks of at most
+// opts.BatchSize messages, without holding the whole campaign in memory. Each
+// chunk is pulled and validated before its request is made, and opts.Messages is
+// ignored. The iterator reports a failure of its source, such as a malformed
+// file, with a non-nil error.
+//
+// The returned iterator sends the chunks as it is consumed and yields each result
+// as soon as it is parsed. A non-nil error stops the iteration; results already
+// yielded belong to messages that were sent, and the messages of a chunk that
+// failed validation, or whose source failed, are not sent. The synthetic results of messages dropped by
+// client-side policies are yielded after the results of their chunk, or before
+// them when no message of the chunk had been pulled yet.
+//
+// The ClientIDs must be unique within a chunk, which is all the memory the check
+// takes. Mitake answers a ClientID already accepted in an earlier chunk with the
+// msgid of the first message, see MessageResult.AlreadySent.
+func (c *Client) SendBatchFrom(ctx context.Context, opts BatchMessagesParams, messages iter.Seq2[Message, error]) iter.Seq2[*MessageResult, error] {
+	return func(yield func(*MessageResult, error) bool) {
+		size := opts.BatchSize
+		if size <= 0 || size > MaxBatchMessages {
+			size = MaxBatchMessages
+		}
+
+		pull, stop := iter.Pull2(messages)
+		defer stop()
+
+		var index int
+		// nextChunk pulls, validates and prepares up to size messages to send, and
+		// collects the synthetic results of the dropped ones on the way, in leading
+		// those pulled before the first message to send. On error, the messages
+		// already prepared are settled as not sent.
+		nextChunk := func() (chunk []*preparedMessage, leading, dropped []*MessageResult, more bool, err error) {
+			seen := make(map[string]int, size)
+			fail := func(err error) ([]*preparedMessage, []*MessageResult, []*MessageResult, bool, error) {
+				settleMessages(ctx, chunk, err)
+				return nil, nil, nil, false, err
+			}
+			for len(chunk) < size {
+				message, err, ok := pull()
+				if !ok {
+					return chunk, leading, dropped, false, nil
+				}
+				if err != nil {
+					return fail(err)
+				}
+				c.fillClientID(&message)
+				ve := new(ValidationError)
+				validateBatchMessage(ve, index, message)
+				checkDuplicateClientID(ve, seen, index, message)
+				if err := ve.err(); err != nil {
+					return fail(err)
+				}
+				index++
+				p, err := c.prepareMessage(ctx, message)
+				if err != nil {
+					return fail(err)
+				}
+				switch {
+				case p.result == nil:
+					chunk = append(chunk, p)
+				case len(chunk) == 0:
+					leading = append(leading, p.result)
+				default:
+					dropped = append(dropped, p.result)
+				}
+			}
+			return chunk, leading, dropped, true, nil
+		}
+		yieldAll := func(results []*MessageResult) bool {
+			for _, result := range results {
+				if !yield(result, nil) {
+					return false
+				}
+			}
+			return true
+		}
+
+		u, _ := url.Parse("b2c/mtk/SmBulkSend")
+		u.RawQuery = c.buildSendBatchQuery(opts).Encode()
+
+		for more := true; more; {
+			chunk, leading, dropped, ok, err := nextChunk()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			more = ok
+			if !yieldAll(leading) {
+				return
+			}
+			if len(chunk) > 0 {
+				cont, err := c.sendBatchChunk(ctx, u.String(), opts.ObjectID, chunk, yield)
+				if err != nil && cont {
+					yield(nil, err)
+				}
+				if err != nil || !cont {
+					return
+				}
+			}
+			if !yieldAll(dropped) {
+				return
+			}
+		}
+	}
+}
+
+// sendBatchChunk posts a chunk, and yields its results as they are parsed. It
+// reports whether the consumer wants more results. Once the consumer stops, the
+// rest of the response is still read, so that the ObjectRegistry records every
+// msgid of the chunk.
+//
+// The body is encoded while it is sent, instead of being built in memory first.
+func (c *Client) sendBatchChunk(ctx context.Context, urlStr, objectID string, chunk []*preparedMessage, yield func(*MessageResult, error) bool) (bool, error) {
+	prepared := make(map[string]*preparedMessage, len(chunk))
+	for _, p := range chunk {
+		prepared[p.ClientID] = p
+	}
+
+	pr, pw := io.Pipe()
+	// Closing the reader stops the writer if the request ends before reading the
+	// whole body, e.g. when the breaker is open.
+	defer pr.Close()
+	go func() {
+		w := bufio.NewWriter(pw)
+		for _, p := range chunk {
+			if err := writeBatchMessage(w, p.Message); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+
+	resp, err := c.Post(ctx, urlStr, "application/x-www-form-urlencoded", pr)
+	if err != nil {
+		settleMessages(ctx, chunk, err)
+		return true, err
+	}
+	defer resp.Body.Close()
+	// The messages without result, after a parse error, count as not sent.
+	defer settleMessages(ctx, chunk, nil)
+
+	var (
+		response = new(MessageResponse)
+		more     = true
+	)
+	err = scanMessageResponse(resp.Body, response, func(result *MessageResult) bool {
+		if p, ok := prepared[result.ClientID]; ok {
+			p.apply(result)
+			p.sent = result
+		}
+		response.Results = append(response.Results, result)
+		if more {
+			more = yield(result, nil)
+		}
+		return true
+	})
+	if err != nil {
+		return more, err
+	}
+	if err := c.recordObject(ctx, objectID, response); err != nil {
+		return more, err
+	}
+	return more, nil
+}
+
+// CSVColumns maps the fields of a Message to the header names of a CSV file.
+// Fields with an empty name are left empty.
+type CSVColumns struct {
+	ClientID string
+	Dstaddr  string
+	Smbody   string
+	Dlvtime  string
+	Vldtime  string
+	Destname string
+	Response string
+}
+
+// CSVMessageReader reads messages from a CSV file whose first record is a header.
+//
+// Example usage:
+//
+//	r := mitake.NewCSVMessageReader(file, mitake.CSVColumns{ClientID: "id", Dstaddr: "phone", Smbody: "text"})
+//	for result, err := range client.SendBatchFrom(ctx, mitake.BatchMessagesParams{}, r.Messages()) {
+//		...
+//	}
+type CSVMessageReader struct {
+	r       *csv.Reader
+	columns CSVColumns
+}
+
+// NewCSVMessageReader returns a CSVMessageReader reading from r.
+func NewCSVMessageReader(r io.Reader, columns CSVColumns) *CSVMessageReader {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	return &CSVMessageReader{r: cr, columns: columns}
+}
+
+// Messages returns an iterator over the messages of the CSV file. The iteration
+// stops after the first error, which is yielded with an empty Message.
+func (r *CSVMessageReader) Messages() iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		header, err := r.r.Read()
+		if err != nil {
+			yield(Message{}, fmt.Errorf("read CSV header: %w", err))
+			return
+		}
+		index := make(map[string]int, len(header))
+		for i, name := range header {
+			index[name] = i
+		}
+		column := func(name string) (int, error) {
+			if name == "" {
+				return -1, nil
+			}
+			i, ok := index[name]
+			if !ok {
+				return -1, fmt.Errorf("CSV column %q not found", name)
+			}
+			return i, nil
+		}
+
+		fields := []struct {
+			name string
+			set  func(*Message, string)
+		}{
+			{r.columns.ClientID, func(m *Message, v string) { m.ClientID = v }},
+			{r.columns.Dstaddr, func(m *Message, v string) { m.Dstaddr = v }},
+			{r.columns.Smbody, func(m *Message, v string) { m.Smbody = v }},
+			{r.columns.Dlvtime, func(m *Message, v string) { m.Dlvtime = v }},
+			{r.columns.Vldtime, func(m *Message, v string) { m.Vldtime = v }},
+			{r.columns.Destname, func(m *Message, v string) { m.Destname = v }},
+			{r.columns.Response, func(m *Message, v string) { m.Response = v }},
+		}
+		indexes := make([]int, len(fields))
+		for i, field := range fields {
+			if indexes[i], err = column(field.name); err != nil {
+				yield(Message{}, err)
+				return
+			}
+		}
+
+		for {
+			record, err := r.r.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(Message{}, err)
+				return
+			}
+			var message Message
+			for i, field := range fields {
+				if indexes[i] >= 0 {
+					field.set(&message, record[indexes[i]])
+				}
+			}
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package mitake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bulkSendHandler echoes a successful result for every message of a bulk send request.
+func bulkSendHandler(t *testing.T, bodies *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		body, _ := io.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+
+		for _, line := range strings.Split(strings.TrimSuffix(string(body), "\r\n"), "\r\n") {
+			clientID := strings.Split(line, "$$")[0]
+			_, _ = fmt.Fprintf(w, "[%s]\nmsgid=#%s\nstatuscode=1\n", clientID, clientID)
+		}
+		_, _ = fmt.Fprint(w, "AccountPoint=99\n")
+	}
+}
+
+// messageSeq returns an iterator over messages without errors.
+func messageSeq(messages []Message) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		for _, message := range messages {
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_SendBatchFrom(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Test2"},
+		{ClientID: "2aab", Dstaddr: "0987654321", Smbody: "Test3"},
+	}
+
+	var clientIDs []string
+	for result, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{BatchSize: 2}, messageSeq(messages)) {
+		if err != nil {
+			t.Fatalf("SendBatchFrom returned unexpected error: %v", err)
+		}
+		if result.Msgid != "#"+result.ClientID {
+			t.Errorf("SendBatchFrom returned msgid %v for %v", result.Msgid, result.ClientID)
+		}
+		clientIDs = append(clientIDs, result.ClientID)
+	}
+
+	if want := []string{"0aab", "1aab", "2aab"}; !reflect.DeepEqual(clientIDs, want) {
+		t.Errorf("SendBatchFrom returned results for %v, want %v", clientIDs, want)
+	}
+	want := []string{
+		"0aab$$0987654321$$$$$$$$$$Test1\r\n1aab$$0987654321$$$$$$$$$$Test2\r\n",
+		"2aab$$0987654321$$$$$$$$$$Test3\r\n",
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("SendBatchFrom sent %q, want %q", bodies, want)
+	}
+}
+
+func TestClient_SendBatchFrom_duplicateClientID(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Test2"},
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test3"},
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test4"},
+	}
+
+	var lastErr error
+	for _, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{BatchSize: 2}, messageSeq(messages)) {
+		lastErr = err
+	}
+
+	// The ClientIDs are checked within a chunk only.
+	want := &ParameterError{Reason: "3: [0aab] duplicate ClientID of message 2"}
+	if !errors.Is(lastErr, want) {
+		t.Errorf("SendBatchFrom returned error %v, want %v", lastErr, want)
+	}
+	if len(bodies) != 1 {
+		t.Errorf("SendBatchFrom made %d requests, want %d", len(bodies), 1)
+	}
+}
+
+func TestClient_SendBatchFrom_invalidMessage(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321"},
+	}
+
+	var lastErr error
+	for _, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{BatchSize: 1}, messageSeq(messages)) {
+		lastErr = err
+	}
+
+	expectedErr := &ParameterError{Reason: "1: [1aab] empty Smbody"}
+	if !errors.Is(lastErr, expectedErr) {
+		t.Errorf("SendBatchFrom returned error %v, want %v", lastErr, expectedErr)
+	}
+	if len(bodies) != 1 {
+		t.Errorf("SendBatchFrom sent %d requests, want %d", len(bodies), 1)
+	}
+}
+
+func TestClient_SendBatchFrom_invalidMessageInChunk(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321"},
+	}
+
+	var lastErr error
+	for _, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{}, messageSeq(messages)) {
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		t.Errorf("SendBatchFrom did not return error")
+	}
+	if len(bodies) != 0 {
+		t.Errorf("SendBatchFrom sent %q, want no request", bodies)
+	}
+}
+
+type failingObjectRegistry struct {
+	*MemoryObjectRegistry
+}
+
+func (r *failingObjectRegistry) Record(ctx context.Context, objectID string, msgids []string) error {
+	_ = r.MemoryObjectRegistry.Record(ctx, objectID, msgids)
+	return errors.New("registry unavailable")
+}
+
+func TestClient_SendBatchFrom_break(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	registry := &failingObjectRegistry{MemoryObjectRegistry: NewMemoryObjectRegistry()}
+	client.Objects = registry
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Test2"},
+	}
+
+	// Breaking out of the loop must not panic when recording the msgids fails.
+	for range client.SendBatchFrom(context.Background(), BatchMessagesParams{ObjectID: "batch1"}, messageSeq(messages)) {
+		break
+	}
+
+	msgids, _ := registry.MessageIDs(context.Background(), "batch1")
+	if want := []string{"#0aab", "#1aab"}; !reflect.DeepEqual(msgids, want) {
+		t.Errorf("SendBatchFrom recorded %v, want %v", msgids, want)
+	}
+}
+
+func TestCSVMessageReader(t *testing.T) {
+	r := NewCSVMessageReader(strings.NewReader("id,phone,name,text\n0aab,0987654321,Bob,\"Hello, 世界\"\n"),
+		CSVColumns{ClientID: "id", Dstaddr: "phone", Destname: "name", Smbody: "text"})
+
+	var actual []Message
+	for message, err := range r.Messages() {
+		if err != nil {
+			t.Fatalf("CSVMessageReader returned unexpected error: %v", err)
+		}
+		actual = append(actual, message)
+	}
+
+	want := []Message{{ClientID: "0aab", Dstaddr: "0987654321", Destname: "Bob", Smbody: "Hello, 世界"}}
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("CSVMessageReader returned %+v, want %+v", actual, want)
+	}
+}
+
+func TestCSVMessageReader_missingColumn(t *testing.T) {
+	r := NewCSVMessageReader(strings.NewReader("id,phone\n0aab,0987654321\n"),
+		CSVColumns{ClientID: "id", Dstaddr: "phone", Smbody: "text"})
+
+	var errs []error
+	for message, err := range r.Messages() {
+		if err == nil {
+			t.Errorf("CSVMessageReader returned %+v, want no messages", message)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("CSVMessageReader returned errors %v, want one error", errs)
+	}
+}
+
+func TestClient_SendBatchFrom_allowList(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.AllowList = &AllowList{Numbers: []string{"0987654321"}}
+
+	messages := []Message{
+		{ClientID: "0aab", Dstaddr: "0911111111", Smbody: "Test1"},
+		{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Test2"},
+		{ClientID: "2aab", Dstaddr: "0911111111", Smbody: "Test3"},
+	}
+
+	var statuses []StatusCode
+	for result, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{}, messageSeq(messages)) {
+		if err != nil {
+			t.Fatalf("SendBatchFrom returned unexpected error: %v", err)
+		}
+		statuses = append(statuses, result.StatusCode)
+	}
+
+	want := []StatusCode{StatusRecipientNotAllowed, StatusCarrierAccepted, StatusRecipientNotAllowed}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("SendBatchFrom returned statuses %v, want %v", statuses, want)
+	}
+	if want := []string{"1aab$$0987654321$$$$$$$$$$Test2\r\n"}; !reflect.DeepEqual(bodies, want) {
+		t.Errorf("SendBatchFrom sent %q, want %q", bodies, want)
+	}
+}
+
+func TestClient_SendBatchFrom_sourceError(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var bodies []string
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", bulkSendHandler(t, &bodies))
+	client.Guard = &Guard{DedupeWindow: time.Minute}
+
+	message := Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Hello"}
+	expectedErr := errors.New("malformed record")
+	messages := func(yield func(Message, error) bool) {
+		if yield(message, nil) {
+			yield(Message{}, expectedErr)
+		}
+	}
+	var lastErr error
+	for _, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{}, messages) {
+		lastErr = err
+	}
+	if !errors.Is(lastErr, expectedErr) {
+		t.Errorf("SendBatchFrom returned error %v, want %v", lastErr, expectedErr)
+	}
+	if len(bodies) != 0 {
+		t.Errorf("SendBatchFrom sent %q, want no request", bodies)
+	}
+
+	// The guard forgets the message that was not sent.
+	for result, err := range client.SendBatchFrom(context.Background(), BatchMessagesParams{}, messageSeq([]Message{message})) {
+		if err != nil {
+			t.Fatalf("SendBatchFrom returned unexpected error: %v", err)
+		}
+		if result.StatusCode != StatusCarrierAccepted {
+			t.Errorf("SendBatchFrom returned status %v, want %v", result.StatusCode, StatusCarrierAccepted)
+		}
+	}
+}
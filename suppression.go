@@ -0,0 +1,280 @@
+package mitake
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suppression is a recipient who must not receive non-transactional messages, for
+// example because they unsubscribed.
+type Suppression struct {
+	Dstaddr   string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// SuppressionStore holds the suppressed recipients by phone number. Phone numbers
+// are compared in the local format, so that +886987654321 matches 0987654321. It
+// must be safe for concurrent use.
+type SuppressionStore interface {
+	// Get returns the suppression of dstaddr, or nil if it is not suppressed.
+	Get(ctx context.Context, dstaddr string) (*Suppression, error)
+	// Add suppresses a recipient, replacing any previous suppression.
+	Add(ctx context.Context, s Suppression) error
+	// Remove lifts the suppression of dstaddr.
+	Remove(ctx context.Context, dstaddr string) error
+	// List returns every suppression, ordered by phone number.
+	List(ctx context.Context) ([]Suppression, error)
+}
+
+// MemorySuppressionStore is a SuppressionStore in memory.
+type MemorySuppressionStore struct {
+	mu           sync.RWMutex
+	suppressions map[string]Suppression
+}
+
+// NewMemorySuppressionStore returns an empty MemorySuppressionStore.
+func NewMemorySuppressionStore() *MemorySuppressionStore {
+	return &MemorySuppressionStore{suppressions: make(map[string]Suppression)}
+}
+
+// Get implements SuppressionStore.
+func (s *MemorySuppressionStore) Get(_ context.Context, dstaddr string) (*Suppression, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	suppression, ok := s.suppressions[normalizePhoneNumber(dstaddr)]
+	if !ok {
+		return nil, nil
+	}
+	return &suppression, nil
+}
+
+// Add implements SuppressionStore.
+func (s *MemorySuppressionStore) Add(_ context.Context, suppression Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	suppression.Dstaddr = normalizePhoneNumber(suppression.Dstaddr)
+	s.suppressions[suppression.Dstaddr] = suppression
+	return nil
+}
+
+// AddAll adds the suppressions at once, see ImportSuppressions.
+func (s *MemorySuppressionStore) AddAll(_ context.Context, suppressions []Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, suppression := range suppressions {
+		suppression.Dstaddr = normalizePhoneNumber(suppression.Dstaddr)
+		s.suppressions[suppression.Dstaddr] = suppression
+	}
+	return nil
+}
+
+// Remove implements SuppressionStore.
+func (s *MemorySuppressionStore) Remove(_ context.Context, dstaddr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.suppressions, normalizePhoneNumber(dstaddr))
+	return nil
+}
+
+// List implements SuppressionStore.
+func (s *MemorySuppressionStore) List(_ context.Context) ([]Suppression, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	suppressions := make([]Suppression, 0, len(s.suppressions))
+	for _, suppression := range s.suppressions {
+		suppressions = append(suppressions, suppression)
+	}
+	slices.SortFunc(suppressions, func(a, b Suppression) int {
+		return strings.Compare(a.Dstaddr, b.Dstaddr)
+	})
+	return suppressions, nil
+}
+
+// FileSuppressionStore is a SuppressionStore kept in memory and saved to a CSV
+// file, in the format of ExportSuppressions, on every change.
+type FileSuppressionStore struct {
+	*MemorySuppressionStore
+	path string
+	mu   sync.Mutex // Serializes the changes, so that the file matches the memory
+}
+
+// OpenFileSuppressionStore loads the suppressions of the file at path, which is
+// created on the first change if it does not exist.
+func OpenFileSuppressionStore(path string) (*FileSuppressionStore, error) {
+	s := &FileSuppressionStore{MemorySuppressionStore: NewMemorySuppressionStore(), path: path}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := ImportSuppressions(context.Background(), s.MemorySuppressionStore, f); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Add implements SuppressionStore.
+func (s *FileSuppressionStore) Add(ctx context.Context, suppression Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemorySuppressionStore.Add(ctx, suppression); err != nil {
+		return err
+	}
+	return s.save(ctx)
+}
+
+// AddAll adds the suppressions at once, saving the file a single time, see
+// ImportSuppressions.
+func (s *FileSuppressionStore) AddAll(ctx context.Context, suppressions []Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemorySuppressionStore.AddAll(ctx, suppressions); err != nil {
+		return err
+	}
+	return s.save(ctx)
+}
+
+// Remove implements SuppressionStore.
+func (s *FileSuppressionStore) Remove(ctx context.Context, dstaddr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemorySuppressionStore.Remove(ctx, dstaddr); err != nil {
+		return err
+	}
+	return s.save(ctx)
+}
+
+// save writes the suppressions to a temporary file, and renames it over the file,
+// so that the file is never left half written.
+func (s *FileSuppressionStore) save(ctx context.Context) error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := ExportSuppressions(ctx, s.MemorySuppressionStore, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// suppressionHeader is the header of the CSV format of the suppressions.
+var suppressionHeader = []string{"dstaddr", "reason", "created_at"}
+
+// ImportSuppressions adds the suppressions read from a CSV file to the store, and
+// returns how many were added. The file has a header with a dstaddr column, and
+// optional reason and created_at columns, the latter in RFC 3339.
+//
+// A store with an AddAll method, such as FileSuppressionStore, gets the
+// suppressions in a single call once the file is read, instead of one Add each.
+func ImportSuppressions(ctx context.Context, store SuppressionStore, r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	if _, ok := columns["dstaddr"]; !ok {
+		return 0, errors.New(`CSV column "dstaddr" not found`)
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var suppressions []Suppression
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return addSuppressions(ctx, store, suppressions)
+		}
+		if err != nil {
+			return addSuppressionsBefore(ctx, store, suppressions, err)
+		}
+		suppression := Suppression{Dstaddr: field(record, "dstaddr"), Reason: field(record, "reason")}
+		if suppression.Dstaddr == "" {
+			continue
+		}
+		if createdAt := field(record, "created_at"); createdAt != "" {
+			if suppression.CreatedAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+				return addSuppressionsBefore(ctx, store, suppressions, fmt.Errorf("%s: invalid created_at %q", suppression.Dstaddr, createdAt))
+			}
+		}
+		suppressions = append(suppressions, suppression)
+	}
+}
+
+// addSuppressions adds the suppressions to the store, at once if it has an AddAll
+// method, and returns how many were added.
+func addSuppressions(ctx context.Context, store SuppressionStore, suppressions []Suppression) (int, error) {
+	if bulk, ok := store.(interface {
+		AddAll(ctx context.Context, suppressions []Suppression) error
+	}); ok {
+		if err := bulk.AddAll(ctx, suppressions); err != nil {
+			return 0, err
+		}
+		return len(suppressions), nil
+	}
+	for i, suppression := range suppressions {
+		if err := store.Add(ctx, suppression); err != nil {
+			return i, err
+		}
+	}
+	return len(suppressions), nil
+}
+
+// addSuppressionsBefore adds the suppressions read before the import failed with
+// err, and returns err.
+func addSuppressionsBefore(ctx context.Context, store SuppressionStore, suppressions []Suppression, err error) (int, error) {
+	n, addErr := addSuppressions(ctx, store, suppressions)
+	if addErr != nil {
+		return n, addErr
+	}
+	return n, err
+}
+
+// ExportSuppressions writes the suppressions of the store to w as CSV, in the
+// format read by ImportSuppressions.
+func ExportSuppressions(ctx context.Context, store SuppressionStore, w io.Writer) error {
+	suppressions, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write(suppressionHeader)
+	for _, s := range suppressions {
+		var createdAt string
+		if !s.CreatedAt.IsZero() {
+			createdAt = s.CreatedAt.Format(time.RFC3339)
+		}
+		_ = cw.Write([]string{s.Dstaddr, s.Reason, createdAt})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,143 @@
+package mitake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportExportSuppressions(t *testing.T) {
+	store := NewMemorySuppressionStore()
+	input := "dstaddr,reason,created_at\n" +
+		"+886987654321,STOP,2017-01-01T00:00:00Z\n" +
+		"0912345678,,\n"
+
+	n, err := ImportSuppressions(context.Background(), store, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ImportSuppressions returned unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ImportSuppressions returned %d, want %d", n, 2)
+	}
+
+	var b strings.Builder
+	if err := ExportSuppressions(context.Background(), store, &b); err != nil {
+		t.Fatalf("ExportSuppressions returned unexpected error: %v", err)
+	}
+	want := "dstaddr,reason,created_at\n" +
+		"0912345678,,\n" +
+		"0987654321,STOP,2017-01-01T00:00:00Z\n"
+	if b.String() != want {
+		t.Errorf("ExportSuppressions wrote %q, want %q", b.String(), want)
+	}
+}
+
+func TestImportSuppressions_missingColumn(t *testing.T) {
+	_, err := ImportSuppressions(context.Background(), NewMemorySuppressionStore(), strings.NewReader("phone\n0987654321\n"))
+	if err == nil {
+		t.Error("ImportSuppressions did not return error")
+	}
+}
+
+func TestFileSuppressionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.csv")
+	ctx := context.Background()
+
+	store, err := OpenFileSuppressionStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSuppressionStore returned unexpected error: %v", err)
+	}
+	createdAt := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	_ = store.Add(ctx, Suppression{Dstaddr: "0987654321", Reason: "STOP", CreatedAt: createdAt})
+	_ = store.Add(ctx, Suppression{Dstaddr: "0912345678"})
+	_ = store.Remove(ctx, "+886912345678")
+
+	reopened, err := OpenFileSuppressionStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSuppressionStore returned unexpected error: %v", err)
+	}
+	suppressions, _ := reopened.List(ctx)
+	want := []Suppression{{Dstaddr: "0987654321", Reason: "STOP", CreatedAt: createdAt}}
+	if !reflect.DeepEqual(suppressions, want) {
+		t.Errorf("FileSuppressionStore loaded %+v, want %+v", suppressions, want)
+	}
+}
+
+// countingSuppressionStore counts the calls to Add.
+type countingSuppressionStore struct {
+	*FileSuppressionStore
+	adds int
+}
+
+func (s *countingSuppressionStore) Add(ctx context.Context, suppression Suppression) error {
+	s.adds++
+	return s.FileSuppressionStore.Add(ctx, suppression)
+}
+
+func TestImportSuppressions_fileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.csv")
+	ctx := context.Background()
+
+	file, err := OpenFileSuppressionStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSuppressionStore returned unexpected error: %v", err)
+	}
+	store := &countingSuppressionStore{FileSuppressionStore: file}
+	input := "dstaddr,reason\n0987654321,STOP\n0912345678,\n0911111111,STOP\n"
+	n, err := ImportSuppressions(ctx, store, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ImportSuppressions returned unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("ImportSuppressions returned %d, want %d", n, 3)
+	}
+	if store.adds != 0 {
+		t.Errorf("ImportSuppressions called Add %d times, want the suppressions added at once", store.adds)
+	}
+
+	reopened, err := OpenFileSuppressionStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileSuppressionStore returned unexpected error: %v", err)
+	}
+	if suppressions, _ := reopened.List(ctx); len(suppressions) != 3 {
+		t.Errorf("FileSuppressionStore loaded %+v, want %d suppressions", suppressions, 3)
+	}
+}
+
+func TestClient_SendBatch_suppressions(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/b2c/mtk/SmBulkSend", func(w http.ResponseWriter, r *http.Request) {
+		testData(t, r, "1aab$$0987654321$$$$$$$$$$Code\r\n2aab$$0912345678$$$$$$$$$$Sale\r\n")
+		_, _ = fmt.Fprint(w, "[1aab]\nmsgid=#1010079523\nstatuscode=1\n[2aab]\nmsgid=#1010079524\nstatuscode=1\nAccountPoint=98\n")
+	})
+	client.Suppressions = NewMemorySuppressionStore()
+	_ = client.Suppressions.Add(context.Background(), Suppression{Dstaddr: "0987654321"})
+
+	resp, err := client.SendBatch(context.Background(), BatchMessagesParams{
+		HideDeductedPoints: true,
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "+886987654321", Smbody: "Sale"},
+			{ClientID: "1aab", Dstaddr: "0987654321", Smbody: "Code", Category: CategoryOTP},
+			{ClientID: "2aab", Dstaddr: "0912345678", Smbody: "Sale"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendBatch returned unexpected error: %v", err)
+	}
+
+	var statuses []StatusCode
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.StatusCode)
+	}
+	want := []StatusCode{StatusOptedOut, StatusCarrierAccepted, StatusCarrierAccepted}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("SendBatch returned statuses %v, want %v", statuses, want)
+	}
+}
@@ -0,0 +1,246 @@
+package mitake
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Template renders message bodies with per-recipient variables.
+//
+// Example usage:
+//
+//	tmpl, err := mitake.ParseSimpleTemplate("{{name}}，您的訂單 {{order}} 已出貨")
+//	if err != nil { ... }
+//	params, warnings, err := tmpl.Batch(mitake.BatchMessagesParams{}, []mitake.Recipient{
+//		{Message: mitake.Message{ClientID: "0aab", Dstaddr: "0987654321"}, Data: map[string]string{"name": "Bob", "order": "A001"}},
+//	})
+type Template struct {
+	variables []string
+	segments  Segments
+	execute   func(data map[string]string) (string, error)
+}
+
+// Recipient is a message to be rendered by a Template. The Smbody of the Message is
+// replaced by the rendered body.
+type Recipient struct {
+	Message
+	Data map[string]string
+}
+
+// TemplateWarning reports a rendered body that costs more than the template suggests.
+type TemplateWarning struct {
+	ClientID string
+	Dstaddr  string
+	Segments Segments // Segments of the rendered body
+	Reason   string
+}
+
+func (w TemplateWarning) String() string {
+	return fmt.Sprintf("[%s] %s", w.ClientID, w.Reason)
+}
+
+// simpleVariable matches the {{name}} placeholders of the simple template syntax.
+var simpleVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// ParseTemplate parses a body in the text/template syntax, where the variables are
+// referenced as fields of the data, for example {{.name}}.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("smbody").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []string
+	if tmpl.Tree != nil {
+		walkTemplateFields(tmpl, func(name string) {
+			if !slices.Contains(variables, name) {
+				variables = append(variables, name)
+			}
+		})
+	}
+
+	return newTemplate(variables, func(data map[string]string) (string, error) {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}), nil
+}
+
+// ParseSimpleTemplate parses a body with {{name}} placeholders.
+func ParseSimpleTemplate(text string) (*Template, error) {
+	var variables []string
+	for _, match := range simpleVariable.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(variables, match[1]) {
+			variables = append(variables, match[1])
+		}
+	}
+
+	return newTemplate(variables, func(data map[string]string) (string, error) {
+		return simpleVariable.ReplaceAllStringFunc(text, func(s string) string {
+			return data[simpleVariable.FindStringSubmatch(s)[1]]
+		}), nil
+	}), nil
+}
+
+func newTemplate(variables []string, execute func(map[string]string) (string, error)) *Template {
+	t := &Template{variables: variables, execute: execute}
+	// The segments of the template are those of the body with every variable empty.
+	empty := make(map[string]string, len(variables))
+	for _, name := range variables {
+		empty[name] = ""
+	}
+	if body, err := execute(empty); err == nil {
+		t.segments = CountSegments(body)
+	}
+	return t
+}
+
+// templateFieldWalker collects the fields referenced on the data of a template,
+// as {{.name}} where dot is the data, or as {{$.name}} anywhere.
+type templateFieldWalker struct {
+	tmpl    *template.Template
+	fn      func(name string)
+	visited map[string]bool // Named templates already walked with the data as dot
+}
+
+// walkTemplateFields calls fn with the name of every field referenced on the data.
+func walkTemplateFields(tmpl *template.Template, fn func(name string)) {
+	w := &templateFieldWalker{tmpl: tmpl, fn: fn, visited: make(map[string]bool)}
+	w.walk(tmpl.Tree.Root, true)
+}
+
+// walk walks node, where root reports whether dot is the data.
+func (w *templateFieldWalker) walk(node parse.Node, root bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, root)
+		}
+	case *parse.ActionNode:
+		w.walk(n.Pipe, root)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			w.walk(cmd, root)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			w.walk(arg, root)
+		}
+	case *parse.ChainNode:
+		w.walk(n.Node, root)
+	case *parse.FieldNode:
+		if root {
+			w.fn(n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			w.fn(n.Ident[1])
+		}
+	case *parse.IfNode:
+		w.walk(n.Pipe, root)
+		w.walk(n.List, root)
+		w.walk(n.ElseList, root)
+	case *parse.WithNode:
+		// Dot is the value of the pipeline in the body, and unchanged in the else.
+		w.walk(n.Pipe, root)
+		w.walk(n.List, false)
+		w.walk(n.ElseList, root)
+	case *parse.RangeNode:
+		w.walk(n.Pipe, root)
+		w.walk(n.List, false)
+		w.walk(n.ElseList, root)
+	case *parse.TemplateNode:
+		w.walk(n.Pipe, root)
+		// The fields of a named template are on the data when it is passed dot.
+		if !root || n.Pipe == nil || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+			return
+		}
+		if _, ok := n.Pipe.Cmds[0].Args[0].(*parse.DotNode); !ok || w.visited[n.Name] {
+			return
+		}
+		w.visited[n.Name] = true
+		if t := w.tmpl.Lookup(n.Name); t != nil && t.Tree != nil {
+			w.walk(t.Tree.Root, true)
+		}
+	}
+}
+
+// Variables returns the names of the variables referenced by the template.
+func (t *Template) Variables() []string {
+	return slices.Clone(t.variables)
+}
+
+// Segments returns the segments of the template with every variable empty.
+func (t *Template) Segments() Segments {
+	return t.segments
+}
+
+// Render renders the body with data. Every variable of the template must be provided.
+func (t *Template) Render(data map[string]string) (string, error) {
+	for _, name := range t.variables {
+		if _, ok := data[name]; !ok {
+			return "", &ParameterError{Reason: fmt.Sprintf("missing template variable %q", name)}
+		}
+	}
+	return t.execute(data)
+}
+
+// Message renders the body for the recipient. A warning is returned when the rendered
+// body needs more segments than the template, or needs the UCS-2 encoding when the
+// template does not.
+func (t *Template) Message(r Recipient) (Message, *TemplateWarning, error) {
+	body, err := t.Render(r.Data)
+	if err != nil {
+		return Message{}, nil, fmt.Errorf("[%s] %w", r.ClientID, err)
+	}
+
+	message := r.Message
+	message.Smbody = body
+
+	segments := CountSegments(body)
+	var reasons []string
+	if segments.UCS2 && !t.segments.UCS2 {
+		reasons = append(reasons, "contains characters that force UCS-2")
+	}
+	if segments.Count > t.segments.Count && segments.Count > 1 {
+		reasons = append(reasons, fmt.Sprintf("needs %d segments, the template needs %d", segments.Count, t.segments.Count))
+	}
+	if len(reasons) == 0 {
+		return message, nil, nil
+	}
+	return message, &TemplateWarning{
+		ClientID: r.ClientID,
+		Dstaddr:  r.Dstaddr,
+		Segments: segments,
+		Reason:   strings.Join(reasons, ", "),
+	}, nil
+}
+
+// Batch renders the messages of the recipients into params, replacing its Messages.
+func (t *Template) Batch(params BatchMessagesParams, recipients []Recipient) (BatchMessagesParams, []TemplateWarning, error) {
+	var warnings []TemplateWarning
+	params.Messages = make([]Message, 0, len(recipients))
+	for _, r := range recipients {
+		message, warning, err := t.Message(r)
+		if err != nil {
+			return params, nil, err
+		}
+		if warning != nil {
+			warnings = append(warnings, *warning)
+		}
+		params.Messages = append(params.Messages, message)
+	}
+	return params, warnings, nil
+}
@@ -0,0 +1,118 @@
+package mitake
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSimpleTemplate(t *testing.T) {
+	tmpl, err := ParseSimpleTemplate("Hi {{name}}, order {{ order }} shipped, {{name}}")
+	if err != nil {
+		t.Fatalf("ParseSimpleTemplate returned unexpected error: %v", err)
+	}
+
+	if want := []string{"name", "order"}; !reflect.DeepEqual(tmpl.Variables(), want) {
+		t.Errorf("Variables returned %v, want %v", tmpl.Variables(), want)
+	}
+
+	body, err := tmpl.Render(map[string]string{"name": "Bob", "order": "A001"})
+	if err != nil {
+		t.Fatalf("Render returned unexpected error: %v", err)
+	}
+	if want := "Hi Bob, order A001 shipped, Bob"; body != want {
+		t.Errorf("Render returned %q, want %q", body, want)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate("Hi {{.name}}{{if .vip}}, welcome back{{end}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate returned unexpected error: %v", err)
+	}
+
+	if want := []string{"name", "vip"}; !reflect.DeepEqual(tmpl.Variables(), want) {
+		t.Errorf("Variables returned %v, want %v", tmpl.Variables(), want)
+	}
+
+	body, err := tmpl.Render(map[string]string{"name": "Bob", "vip": "y"})
+	if err != nil {
+		t.Fatalf("Render returned unexpected error: %v", err)
+	}
+	if want := "Hi Bob, welcome back"; body != want {
+		t.Errorf("Render returned %q, want %q", body, want)
+	}
+}
+
+func TestParseTemplate_nestedVariables(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{define "greet"}}Hi {{.name}}{{end}}` +
+		`{{template "greet" .}}{{with .order}}, order {{.}} of {{$.shop}}{{else}}{{.fallback}}{{end}}` +
+		`{{range $i, $c := .coupon}}{{$c}}{{$.footer}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate returned unexpected error: %v", err)
+	}
+
+	want := []string{"name", "order", "shop", "fallback", "coupon", "footer"}
+	if !reflect.DeepEqual(tmpl.Variables(), want) {
+		t.Errorf("Variables returned %v, want %v", tmpl.Variables(), want)
+	}
+}
+
+func TestTemplate_Render_missingVariable(t *testing.T) {
+	tmpl, _ := ParseSimpleTemplate("Hi {{name}}")
+
+	_, err := tmpl.Render(map[string]string{})
+
+	expectedErr := &ParameterError{Reason: `missing template variable "name"`}
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("Render returned error %v, want %v", err, expectedErr)
+	}
+}
+
+func TestTemplate_Message_missingVariable(t *testing.T) {
+	tmpl, _ := ParseSimpleTemplate("Hi {{name}}")
+
+	_, _, err := tmpl.Message(Recipient{Message: Message{ClientID: "0aab"}})
+
+	var pe *ParameterError
+	if !errors.As(err, &pe) || pe.Reason != `missing template variable "name"` {
+		t.Errorf("Message returned error %v, want the wrapped ParameterError", err)
+	}
+	if want := `[0aab] missing template variable "name"`; err == nil || err.Error() != want {
+		t.Errorf("Message returned error %v, want %v", err, want)
+	}
+}
+
+func TestTemplate_Batch(t *testing.T) {
+	tmpl, _ := ParseSimpleTemplate("Hi {{name}}")
+
+	params, warnings, err := tmpl.Batch(BatchMessagesParams{ObjectID: "batch1"}, []Recipient{
+		{Message: Message{ClientID: "0aab", Dstaddr: "0987654321"}, Data: map[string]string{"name": "Bob"}},
+		{Message: Message{ClientID: "1aab", Dstaddr: "0987654322"}, Data: map[string]string{"name": "小明"}},
+		{Message: Message{ClientID: "2aab", Dstaddr: "0987654323"}, Data: map[string]string{"name": strings.Repeat("a", 160)}},
+	})
+	if err != nil {
+		t.Fatalf("Batch returned unexpected error: %v", err)
+	}
+
+	want := BatchMessagesParams{
+		ObjectID: "batch1",
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Hi Bob"},
+			{ClientID: "1aab", Dstaddr: "0987654322", Smbody: "Hi 小明"},
+			{ClientID: "2aab", Dstaddr: "0987654323", Smbody: "Hi " + strings.Repeat("a", 160)},
+		},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("Batch returned %+v, want %+v", params, want)
+	}
+
+	var clientIDs []string
+	for _, warning := range warnings {
+		clientIDs = append(clientIDs, warning.ClientID)
+	}
+	if want := []string{"1aab", "2aab"}; !reflect.DeepEqual(clientIDs, want) {
+		t.Errorf("Batch returned warnings %v, want warnings for %v", warnings, want)
+	}
+}
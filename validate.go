@@ -0,0 +1,246 @@
+package mitake
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of the fields of a batch message.
+const (
+	MaxClientIDLength = 36 // Characters of a ClientID
+	MaxDestnameLength = 36 // Characters of a Destname
+	MaxSmbodySegments = 5  // Segments of a Smbody, see CountSegments
+)
+
+// Rules of the FieldErrors.
+const (
+	RuleRequired       = "required"        // The field is empty
+	RuleMaxLength      = "max_length"      // The field is too long
+	RuleCharset        = "charset"         // The field contains characters that are not allowed
+	RuleFormat         = "format"          // The field is malformed
+	RuleUnique         = "unique"          // An earlier message of the batch has the same value
+	RuleOrder          = "order"           // The field is not after another one
+	RuleSeparator      = "separator"       // The field contains the separator of the bulk format
+	RuleLineBreak      = "line_break"      // The field contains a line break
+	RuleTrailingDollar = "trailing_dollar" // The field ends with "$"
+)
+
+var (
+	clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	// phonePattern matches the phone numbers, local or international, once their
+	// separators are removed.
+	phonePattern = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
+// FieldError describes a field that failed a validation rule. Its Value is left
+// out for the Smbody, which may hold a one-time passcode, and masked but for its
+// last digits for the Dstaddr, so that the errors can be logged and returned to
+// the callers as is.
+type FieldError struct {
+	Index    int    `json:"index"`               // Index of the message in the batch, -1 outside of a batch
+	ClientID string `json:"client_id,omitempty"` // ClientID of the message
+	Field    string `json:"field"`               // Name of the field, such as "Dstaddr"
+	Rule     string `json:"rule"`                // Rule that failed, such as RuleRequired
+	Value    string `json:"value,omitempty"`     // Value of the field, see FieldError
+	Message  string `json:"message"`             // Description of the problem
+}
+
+// Error returns the description of the problem, prefixed in a batch by the index
+// and the ClientID of the message, such as "1: [1aab] empty Smbody".
+func (e *FieldError) Error() string {
+	if e.Index < 0 {
+		return e.Message
+	}
+	if e.ClientID == "" {
+		return fmt.Sprintf("%d: %s", e.Index, e.Message)
+	}
+	return fmt.Sprintf("%d: [%s] %s", e.Index, e.ClientID, e.Message)
+}
+
+// ValidationError lists every invalid field of a request. It unwraps to a
+// ParameterError per field, so that errors.Is and errors.As keep working with
+// ParameterError.
+//
+// Example usage:
+//
+//	var ve *mitake.ValidationError
+//	if errors.As(err, &ve) {
+//		for _, fe := range ve.Errors {
+//			log.Printf("message %d: %s %s", fe.Index, fe.Field, fe.Rule)
+//		}
+//	}
+type ValidationError struct {
+	Errors []*FieldError `json:"errors"`
+}
+
+// Error returns the errors of the fields, one per line.
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = fe.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns a ParameterError per field.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = &ParameterError{Reason: fe.Error()}
+	}
+	return errs
+}
+
+// err returns e, or nil if it has no errors.
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// add records that the field of the i-th message failed the rule.
+func (e *ValidationError) add(i int, message Message, field, rule, value, format string, args ...any) {
+	switch field {
+	case "Smbody":
+		value = ""
+	case "Dstaddr":
+		value = maskPhoneNumber(value)
+	}
+	e.Errors = append(e.Errors, &FieldError{
+		Index:    i,
+		ClientID: message.ClientID,
+		Field:    field,
+		Rule:     rule,
+		Value:    value,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate validates every message of the batch, and returns a ValidationError
+// listing all the invalid fields, or nil.
+func (p BatchMessagesParams) Validate() error {
+	ve := new(ValidationError)
+	if len(p.Messages) == 0 {
+		ve.add(-1, Message{}, "Messages", RuleRequired, "", "empty messages")
+		return ve
+	}
+	seen := make(map[string]int, len(p.Messages))
+	for i, message := range p.Messages {
+		validateBatchMessage(ve, i, message)
+		checkDuplicateClientID(ve, seen, i, message)
+	}
+	return ve.err()
+}
+
+// checkDuplicateClientID records the ClientID of the i-th message in seen, and
+// adds an error to ve if an earlier message has the same one.
+func checkDuplicateClientID(ve *ValidationError, seen map[string]int, i int, message Message) {
+	if message.ClientID == "" {
+		return
+	}
+	if j, ok := seen[message.ClientID]; ok {
+		ve.add(i, message, "ClientID", RuleUnique, message.ClientID, "duplicate ClientID of message %d", j)
+		return
+	}
+	seen[message.ClientID] = i
+}
+
+// validateBatchMessage adds the errors of the i-th message of a batch to ve.
+func validateBatchMessage(ve *ValidationError, i int, message Message) {
+	switch {
+	case message.ClientID == "":
+		ve.add(i, message, "ClientID", RuleRequired, "", "empty ClientID")
+	case utf8.RuneCountInString(message.ClientID) > MaxClientIDLength:
+		ve.add(i, message, "ClientID", RuleMaxLength, message.ClientID, "ClientID longer than %d characters", MaxClientIDLength)
+	case !clientIDPattern.MatchString(message.ClientID):
+		ve.add(i, message, "ClientID", RuleCharset, message.ClientID,
+			"ClientID contains characters other than letters, digits, \"_\", \".\" and \"-\"")
+	}
+	validateDstaddr(ve, i, message)
+	switch {
+	case message.Smbody == "":
+		ve.add(i, message, "Smbody", RuleRequired, "", "empty Smbody")
+	case CountSegments(message.Smbody).Count > MaxSmbodySegments:
+		ve.add(i, message, "Smbody", RuleMaxLength, message.Smbody, "Smbody longer than %d segments", MaxSmbodySegments)
+	}
+	if utf8.RuneCountInString(message.Destname) > MaxDestnameLength {
+		ve.add(i, message, "Destname", RuleMaxLength, message.Destname, "Destname longer than %d characters", MaxDestnameLength)
+	}
+
+	dlvtime, dlvErr := ParseTime(message.Dlvtime)
+	if message.Dlvtime != "" && dlvErr != nil {
+		ve.add(i, message, "Dlvtime", RuleFormat, message.Dlvtime, "invalid Dlvtime %q", message.Dlvtime)
+	}
+	// A number of seconds is relative to the delivery, so always after it.
+	if _, ok := vldtimeSeconds(message.Vldtime); !ok {
+		vldtime, vldErr := ParseTime(message.Vldtime)
+		if message.Vldtime != "" && vldErr != nil {
+			ve.add(i, message, "Vldtime", RuleFormat, message.Vldtime, "invalid Vldtime %q", message.Vldtime)
+		}
+		if dlvErr == nil && vldErr == nil && !vldtime.After(dlvtime) {
+			ve.add(i, message, "Vldtime", RuleOrder, message.Vldtime, "Vldtime %s not after Dlvtime %s", message.Vldtime, message.Dlvtime)
+		}
+	}
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"ClientID", message.ClientID},
+		{"Dstaddr", message.Dstaddr},
+		{"Dlvtime", message.Dlvtime},
+		{"Vldtime", message.Vldtime},
+		{"Destname", message.Destname},
+		{"Response", message.Response},
+		{"Smbody", message.Smbody},
+	}
+	for _, field := range fields {
+		if strings.Contains(field.value, batchFieldSeparator) {
+			ve.add(i, message, field.name, RuleSeparator, field.value,
+				"%s contains field separator %q", field.name, batchFieldSeparator)
+		}
+		// Smbody is the last field and its newlines are encoded, any other field must
+		// fit on the line and must not merge a trailing "$" into the separator.
+		if field.name == "Smbody" {
+			continue
+		}
+		if strings.ContainsAny(field.value, "\r\n") {
+			ve.add(i, message, field.name, RuleLineBreak, field.value, "%s contains line break", field.name)
+		}
+		if strings.HasSuffix(field.value, "$") {
+			ve.add(i, message, field.name, RuleTrailingDollar, field.value, "%s ends with \"$\"", field.name)
+		}
+	}
+}
+
+// validateDstaddr adds the error of the Dstaddr of the i-th message to ve, the same
+// for the messages sent alone and in a batch. Any phone number of 8 to 15 digits is
+// accepted, with an optional leading "+" and the separators of the AllowList.
+func validateDstaddr(ve *ValidationError, i int, message Message) {
+	switch {
+	case message.Dstaddr == "":
+		ve.add(i, message, "Dstaddr", RuleRequired, "", "empty Dstaddr")
+	case !phonePattern.MatchString(stripPhoneSeparators(message.Dstaddr)):
+		ve.add(i, message, "Dstaddr", RuleFormat, message.Dstaddr, "invalid Dstaddr %q", maskPhoneNumber(message.Dstaddr))
+	}
+}
+
+// maskPhoneNumber replaces the characters of a phone number but the last 3 with "*".
+func maskPhoneNumber(s string) string {
+	runes := []rune(s)
+	for i := range len(runes) - 3 {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// validateMessage adds the errors of a message sent alone to ve.
+func validateMessage(ve *ValidationError, message Message) {
+	validateDstaddr(ve, -1, message)
+	if message.Smbody == "" {
+		ve.add(-1, message, "Smbody", RuleRequired, "", "empty Smbody")
+	}
+}
@@ -0,0 +1,170 @@
+package mitake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatchMessagesParams_Validate_rules(t *testing.T) {
+	valid := Message{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"}
+	testCases := []struct {
+		edit func(m *Message)
+		want string
+	}{
+		{func(m *Message) { m.ClientID = strings.Repeat("a", 37) }, "0: [" + strings.Repeat("a", 37) + "] ClientID longer than 36 characters"},
+		{func(m *Message) { m.ClientID = "a b" }, `0: [a b] ClientID contains characters other than letters, digits, "_", "." and "-"`},
+		{func(m *Message) { m.Dstaddr = "12345" }, `0: [0aab] invalid Dstaddr "**345"`},
+		{func(m *Message) { m.Dstaddr = "+886 987-654-321" }, ""},
+		{func(m *Message) { m.Dstaddr = "+81 90-1234-5678" }, ""},
+		{func(m *Message) { m.Dstaddr = "0987654321x" }, `0: [0aab] invalid Dstaddr "********21x"`},
+		{func(m *Message) { m.Smbody = strings.Repeat("中", 5*ucs2PartLength+1) }, "0: [0aab] Smbody longer than 5 segments"},
+		{func(m *Message) { m.Destname = strings.Repeat("名", 37) }, "0: [0aab] Destname longer than 36 characters"},
+		{func(m *Message) { m.Dlvtime = "2026-01-01" }, `0: [0aab] invalid Dlvtime "2026-01-01"`},
+		{func(m *Message) { m.Vldtime = "tomorrow" }, `0: [0aab] invalid Vldtime "tomorrow"`},
+		{func(m *Message) { m.Dlvtime, m.Vldtime = "20260102000000", "20260101000000" }, "0: [0aab] Vldtime 20260101000000 not after Dlvtime 20260102000000"},
+		{func(m *Message) { m.Dlvtime, m.Vldtime = "20260101000000", "20260102000000" }, ""},
+		{func(m *Message) { m.Dlvtime, m.Vldtime = "20260101000000", "3600" }, ""},
+		{func(m *Message) { m.Vldtime = "0" }, `0: [0aab] invalid Vldtime "0"`},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case=%d", i), func(t *testing.T) {
+			message := valid
+			tc.edit(&message)
+			err := BatchMessagesParams{Messages: []Message{message}}.Validate()
+
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("Validate returned unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, &ParameterError{Reason: tc.want}) {
+				t.Errorf("Validate returned error %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMessageParams_Validate(t *testing.T) {
+	for dstaddr, want := range map[string]string{
+		"0987654321":       "",
+		"+81 90-1234-5678": "",
+		"12345":            `invalid Dstaddr "**345"`,
+		"":                 "empty Dstaddr",
+	} {
+		err := MessageParams{Message: Message{Dstaddr: dstaddr, Smbody: "Test"}}.Validate()
+		if want == "" {
+			if err != nil {
+				t.Errorf("Validate(%q) returned unexpected error: %v", dstaddr, err)
+			}
+			continue
+		}
+		if !errors.Is(err, &ParameterError{Reason: want}) {
+			t.Errorf("Validate(%q) returned error %v, want %v", dstaddr, err, want)
+		}
+	}
+}
+
+func TestBatchMessagesParams_Validate_allErrors(t *testing.T) {
+	params := BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+			{ClientID: "0aab", Smbody: "Test2", Destname: "Bob$"},
+			{Dstaddr: "0987654321"},
+		},
+	}
+
+	err := params.Validate()
+
+	want := []string{
+		"1: [0aab] empty Dstaddr",
+		`1: [0aab] Destname ends with "$"`,
+		"1: [0aab] duplicate ClientID of message 0",
+		"2: empty ClientID",
+		"2: empty Smbody",
+	}
+	for _, reason := range want {
+		if !errors.Is(err, &ParameterError{Reason: reason}) {
+			t.Errorf("Validate returned error %v, want %v", err, reason)
+		}
+	}
+	if got := strings.Count(err.Error(), "\n") + 1; got != len(want) {
+		t.Errorf("Validate returned %d errors, want %d:\n%v", got, len(want), err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	params := BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321", Smbody: "Test1"},
+			{ClientID: "0aab", Dstaddr: "12345", Smbody: "Test2"},
+		},
+	}
+
+	var ve *ValidationError
+	if err := params.Validate(); !errors.As(err, &ve) {
+		t.Fatalf("Validate returned error %v, want a ValidationError", err)
+	}
+	want := []*FieldError{
+		{Index: 1, ClientID: "0aab", Field: "Dstaddr", Rule: RuleFormat, Value: "**345", Message: `invalid Dstaddr "**345"`},
+		{Index: 1, ClientID: "0aab", Field: "ClientID", Rule: RuleUnique, Value: "0aab", Message: "duplicate ClientID of message 0"},
+	}
+	if !reflect.DeepEqual(ve.Errors, want) {
+		t.Errorf("Validate returned field errors %+v, want %+v", ve.Errors, want)
+	}
+
+	b, err := json.Marshal(ve)
+	if err != nil {
+		t.Fatalf("Marshal returned unexpected error: %v", err)
+	}
+	wantJSON := `{"errors":[` +
+		`{"index":1,"client_id":"0aab","field":"Dstaddr","rule":"format","value":"**345","message":"invalid Dstaddr \"**345\""},` +
+		`{"index":1,"client_id":"0aab","field":"ClientID","rule":"unique","value":"0aab","message":"duplicate ClientID of message 0"}]}`
+	if got := string(b); got != wantJSON {
+		t.Errorf("Marshal returned %s, want %s", got, wantJSON)
+	}
+}
+
+func TestValidationError_sensitiveValues(t *testing.T) {
+	params := BatchMessagesParams{
+		Messages: []Message{
+			{ClientID: "0aab", Dstaddr: "0987654321$", Smbody: "Your code is 1234$$"},
+		},
+	}
+
+	var ve *ValidationError
+	if err := params.Validate(); !errors.As(err, &ve) {
+		t.Fatalf("Validate returned error %v, want a ValidationError", err)
+	}
+	for _, fe := range ve.Errors {
+		switch {
+		case fe.Field == "Smbody" && fe.Value != "":
+			t.Errorf("Validate returned Smbody value %q, want none", fe.Value)
+		case fe.Field == "Dstaddr" && fe.Value != "********21$":
+			t.Errorf("Validate returned Dstaddr value %q, want %q", fe.Value, "********21$")
+		}
+	}
+	if strings.Contains(ve.Error(), "1234") || strings.Contains(ve.Error(), "0987654321") {
+		t.Errorf("Validate returned error %q revealing the message", ve.Error())
+	}
+}
+
+func TestMessageParams_Validate_allErrors(t *testing.T) {
+	err := MessageParams{Message: Message{ClientID: "0aab"}}.Validate()
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Validate returned error %v, want a ValidationError", err)
+	}
+	if got, want := err.Error(), "empty Dstaddr\nempty Smbody"; got != want {
+		t.Errorf("Validate returned error %q, want %q", got, want)
+	}
+	var pe *ParameterError
+	if !errors.As(err, &pe) || pe.Reason != "empty Dstaddr" {
+		t.Errorf("Validate returned error %v, want the ParameterError of the first field", err)
+	}
+}